@@ -0,0 +1,80 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	b "github.com/Jackd4w/GoGEM/pkg/Backup"
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+)
+
+var backup_dir string
+var backup bool
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Save everything that is live on your Wiki to your PC",
+	Long: `Save a snapshot of your Wiki from the iGEM Servers.
+	Every page and template of your team is saved as raw wikitext, every referenced file from /wiki/images/ is downloaded.
+	An index.json in the backup directory lists all saved pages and files. Usefull as a recovery point before uploading or purging.
+	Usage: GoGEM backup -u "[Username]" -y [Wiki Year] -t "[Teamname]" -d "[Backup Directory]"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Establish connection with iGEM Servers
+		session := login()
+		if session == nil {
+			return
+		}
+		defer session.Close()
+
+		createBackup(session)
+		println("Logging out")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+
+	backupCmd.Flags().StringVarP(&username, "username", "u", "", "Username(required)")
+	backupCmd.MarkFlagRequired("username")
+	backupCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year(required)")
+	backupCmd.MarkFlagRequired("year")
+	backupCmd.Flags().StringVarP(&teamname, "teamname", "t", "", "Teamname(required)")
+	backupCmd.MarkFlagRequired("teamname")
+
+	backupCmd.Flags().StringVarP(&password, "password", "p", "", "Password")
+	backupCmd.Flags().StringVarP(&offset, "offset", "o", "", "Offset from your Teams Namespace root")
+	backupCmd.Flags().StringVarP(&backup_dir, "dir", "d", "", "Backup Directory; Standard: backup-[Teamname]-[Timestamp] in the current working directory")
+	backupCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+}
+
+/*
+Backs up the Wiki with the given session, shared by all commands that offer a backup before changing the Wiki.
+Returns false if the backup failed.
+*/
+func createBackup(session *h.Handler) bool {
+	println(fmt.Sprintf("Creating backup of %s/Team:%s", session.BaseURL(), teamname))
+	path, err := b.Backup(backup_dir, session)
+	if err != nil {
+		println("Backup failed: " + err.Error())
+		return false
+	}
+	println("Backup saved to " + path)
+	return true
+}
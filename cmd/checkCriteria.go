@@ -16,31 +16,57 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
 	cc "github.com/Jackd4w/GoGEM/pkg/checkCriteria"
 	"github.com/spf13/cobra"
 )
 
 var url bool
+var report_format string
+var report_file string
+var strict bool
 
 var checkCriteriaCMD = &cobra.Command{
 	Use:   "checkCriteria",
 	Short: "Check Medal Criteria URLs",
 	Long: `Check if your Wiki fullfils the URL Criteria for Medals.
-			Award and MedalCriteria are defined in ...
-			Usage: GoGEM checkcriteria -y [year] -t [teamname] -u [boolean]`,
+			Award and MedalCriteria are defined in the profile of the year, or in the URLs and Order of the config file.
+			Every visible page is also checked for broken links and images, external resources that iGEM blocks and redirects,
+			and against the MinLength, Sections and Keywords configured for its criterion in the Criteria of the config file.
+			The report can be rendered as text, json, markdown or junit (for CI pipelines) and written to a file.
+			With --strict the command fails if any criterion is not OK, so it can gate a deployment.
+			The pages are checked concurrently, a page that does not answer within --timeout seconds is reported as Error.
+			Usage: GoGEM checkcriteria -y [year] -t [teamname] -u [boolean] -f [text|json|markdown|junit] -r [report file]`,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		results, err := cc.CheckCriteria(config.URLORDER, config.URLS, teamname, year, url)
-		if err != nil {
-			log.Fatal(err)
+		prof := activeProfile()
+		if prof == nil {
+			return
 		}
+		results := cc.CheckCriteria(prof.Order, prof.URLs(), prof.Criteria, prof.BaseURL, teamname, timeout)
 
 		println("This is only a guideline! URLs and Criteria may not be up to date!")
 		println("ABSOLUTELY NO WARRENTY FOR THE GENERATED RESULTS")
 
-		println(results)
+		if report_format == "" && out.Structured() {
+			for _, result := range results {
+				if !result.Section {
+					out.Emit(out.Event{Type: out.Criterion, URL: result.URL, Data: result})
+				}
+			}
+		} else if err := writeReport(results); err != nil {
+			log.Fatal(err)
+		}
+
+		if strict && !cc.Passed(results) {
+			out.Flush()
+			os.Exit(1)
+		}
 	},
 }
 
@@ -52,5 +78,42 @@ func init() {
 	checkCriteriaCMD.Flags().StringVarP(&teamname, "teamname", "t", "", "Teamname(required)")
 	checkCriteriaCMD.MarkFlagRequired("teamname")
 	checkCriteriaCMD.Flags().BoolVarP(&url, "url", "u", false, "Print URLs")
+	checkCriteriaCMD.Flags().StringVarP(&report_format, "format", "f", "", "Report format: text, json, markdown or junit; Standard: text, or events if --output is json or ndjson")
+	checkCriteriaCMD.Flags().StringVarP(&report_file, "report", "r", "", "Writes the report to this file instead of the terminal")
+	checkCriteriaCMD.Flags().BoolVarP(&strict, "strict", "s", false, "Exits with status 1 if any criterion is not OK")
+	checkCriteriaCMD.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds for every page")
+
+}
+
+/*
+	Renders the results in the requested format, and writes them to the report file or the terminal
+*/
+func writeReport(results []cc.CriterionResult) error {
+	var report []byte
+	var err error
+	switch report_format {
+	case "", "text":
+		report = []byte(cc.RenderText(results, url))
+	case "json":
+		report, err = cc.RenderJSON(results)
+	case "markdown":
+		report = []byte(cc.RenderMarkdown(results))
+	case "junit":
+		report, err = cc.RenderJUnit(results)
+	default:
+		return fmt.Errorf("unknown report format %s, use text, json, markdown or junit", report_format)
+	}
+	if err != nil {
+		return err
+	}
 
+	if report_file != "" {
+		return ioutil.WriteFile(report_file, report, 0644)
+	}
+	if report_format == "" || report_format == "text" {
+		println(string(report)) // Like all other messages
+		return nil
+	}
+	_, err = os.Stdout.Write(append(report, '\n'))
+	return err
 }
@@ -0,0 +1,113 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	d "github.com/Jackd4w/GoGEM/pkg/Diff"
+	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
+	wp "github.com/Jackd4w/GoGEM/pkg/GoStatic"
+)
+
+var unified bool
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show which pages would change on your Wiki",
+	Long: `Compares your prepared WordPress Page with what is live on the iGEM Servers, without changing anything on the Wiki.
+	Every page is listed as new, changed, unchanged or orphaned (only on the Wiki).
+	Either your WordPress Page gets cloned and prepared like upload does, or an already prepared project directory is used (i.e. from upload -c=false).
+	Media files are not uploaded, links to them are only replaced if they already exist on the Wiki.
+	Usage: GoGEM diff -u "[Username]" -y [year] -t "[Teamname]" -w "[WP URL]" -o "[offset]"
+	       GoGEM diff -u "[Username]" -y [year] -t "[Teamname]" -d "[Project Directory]" -o "[offset]"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if wpurl == "" && project_dir == "" {
+			println("Please specify either your WordPress URL or a prepared project directory")
+			return
+		}
+
+		// Establish connection with iGEM Servers
+		session := login()
+		if session == nil {
+			return
+		}
+		defer session.Close()
+		prof := activeProfile() // Already loaded by login
+
+		var err error
+		project_path := project_dir
+		if project_path == "" {
+			println("Cloning WordPress Page...")
+			project_path, err = wp.GoStatic(wpurl, "", crawlOptions(prof))
+			if err != nil {
+				println(err.Error())
+				return
+			}
+			defer cleanUp(project_path)
+
+			println("Preparing files...")
+			if err := fh.PrepareFiles(teamTemplates(prof), project_path, prof.MathJaxURL, session, false); err != "" {
+				println(err)
+			}
+		}
+
+		println(fmt.Sprintf("Comparing with %s/Team:%s", session.BaseURL(), teamname))
+		diffs, err := d.Compare(project_path, session)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+
+		count := make(map[d.State]int)
+		for _, diff := range diffs {
+			count[diff.State]++
+			println(fmt.Sprintf("%-10s %s", diff.State, diff.URL))
+			if unified && (diff.State == d.Changed || diff.State == d.New) {
+				println(diff.Unified(3))
+			}
+		}
+		println("")
+		println(fmt.Sprintf("%d new, %d changed, %d unchanged, %d orphaned", count[d.New], count[d.Changed], count[d.Unchanged], count[d.Orphaned]))
+		println("Logging out")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&username, "username", "u", "", "Username(required)")
+	diffCmd.MarkFlagRequired("username")
+	diffCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year(required)")
+	diffCmd.MarkFlagRequired("year")
+	diffCmd.Flags().StringVarP(&teamname, "teamname", "t", "", "Teamname(required)")
+	diffCmd.MarkFlagRequired("teamname")
+	diffCmd.Flags().StringVarP(&wpurl, "wpurl", "w", "", "WordPress URL")
+	diffCmd.Flags().StringVarP(&project_dir, "dir", "d", "", "Prepared Project Directory, used instead of cloning the WordPress Page")
+	diffCmd.Flags().StringVarP(&password, "password", "p", "", "Password")
+	diffCmd.Flags().StringVarP(&offset, "offset", "o", "", "Offset from your Teams Namespace root")
+	diffCmd.Flags().BoolVarP(&clean, "clean", "c", true, "Cleanup the temporary files")
+	diffCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Ignores HTTPS Certificate warnings")
+	diffCmd.Flags().BoolVar(&script_assets, "script-assets", false, "Finds files referenced in the scripts of your WordPress Page, like upload --script-assets")
+	diffCmd.Flags().StringVar(&cache_dir, "cache-dir", "", "Directory of the stored responses of the crawl, like upload --cache-dir")
+	diffCmd.Flags().BoolVar(&offline, "offline", false, "Clones your WordPress Page from the stored responses of the last crawl, like upload --offline")
+	addCrawlFlags(diffCmd)
+	diffCmd.Flags().BoolVarP(&unified, "unified", "U", false, "Prints unified diffs of new and changed pages")
+	diffCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+}
@@ -17,12 +17,22 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	GoGEMgostatic "github.com/Jackd4w/GoGEM/pkg/GoStatic"
+	p "github.com/Jackd4w/GoGEM/pkg/Profile"
 	"github.com/spf13/cobra"
 )
 
 var project_dir string
+var script_assets bool
+var cache_dir string
+var offline bool
+var parallelism int
+var delay time.Duration
+var random_delay time.Duration
+var user_agent string
+var robots bool
 
 // fetchWPCmd represents the fetchWP command
 var fetchWPCmd = &cobra.Command{
@@ -30,14 +40,24 @@ var fetchWPCmd = &cobra.Command{
 	Short: "Clone a WordPress Site to your PC, maintaining all static functionality",
 	Long: `Clone a WordPress Site to your PC, maintaining all static functionality.
 		It is important that you specify the used protocol (http or https) in the URL.
+		With --script-assets the scripts are searched for files of your site (i.e. slider images), the literals that could not be resolved are listed in script-assets.json.
+		Every response is stored in the cache directory (--cache-dir), with --offline the project is rebuilt from it without network access.
+		The site is crawled with --parallel requests at a time, --delay and --random-delay slow the crawl down for cheap hosting, --robots respects the robots.txt of the site.
+		Every visited url is listed in crawl-report.txt and crawl-report.json in the project.
 		Useage: GoGEM fetchWP [URL]`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 
+		prof := activeProfile() // Fonts of the wiki of the year
+		if prof == nil {
+			return
+		}
 		fmt.Println("Cloning WordPress Site")
 		fmt.Println("URL:", args[0])
 
-		GoGEMgostatic.GoStatic(args[0], project_dir, config.FONTS, insecure)
+		if _, err := GoGEMgostatic.GoStatic(args[0], project_dir, crawlOptions(prof)); err != nil {
+			fmt.Println(err)
+		}
 	},
 }
 
@@ -45,5 +65,29 @@ func init() {
 	rootCmd.AddCommand(fetchWPCmd)
 
 	fetchWPCmd.Flags().StringVarP(&project_dir, "dir", "d", "", "Project Directory; Standard: current working directory")
+	fetchWPCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year of the wiki the fonts are taken from")
 	fetchWPCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Ignores HTTPS Certificate warnings")
+	fetchWPCmd.Flags().BoolVar(&script_assets, "script-assets", false, "Finds files referenced in the scripts of your site and downloads them")
+	fetchWPCmd.Flags().StringVar(&cache_dir, "cache-dir", "", "Directory of the stored responses; Standard: GoGEM/crawl in your cache directory")
+	fetchWPCmd.Flags().BoolVar(&offline, "offline", false, "Rebuilds the project from the stored responses of the last crawl, without network access")
+	addCrawlFlags(fetchWPCmd)
+}
+
+/*
+	Adds the flags limiting the crawl of the WordPress Page, shared by every command that clones it
+*/
+func addCrawlFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&parallelism, "parallel", 4, "Number of parallel requests to your WordPress Page")
+	cmd.Flags().DurationVar(&delay, "delay", 0, "Delay between two requests, i.e. 500ms")
+	cmd.Flags().DurationVar(&random_delay, "random-delay", 0, "Maximum random delay added to --delay")
+	cmd.Flags().StringVar(&user_agent, "user-agent", GoGEMgostatic.DefaultUserAgent, "User-Agent of the requests")
+	cmd.Flags().BoolVar(&robots, "robots", false, "Respects the robots.txt of your WordPress Page")
+}
+
+/*
+	Options for cloning the WordPress Page, shared by every command that clones it
+*/
+func crawlOptions(prof *p.Profile) GoGEMgostatic.Options {
+	return GoGEMgostatic.Options{Fonts: prof.Fonts, Insecure: insecure, ScriptAssets: script_assets, CacheDir: cache_dir, Offline: offline,
+		Parallelism: parallelism, Delay: delay, RandomDelay: random_delay, UserAgent: user_agent, Robots: robots}
 }
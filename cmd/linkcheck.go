@@ -0,0 +1,95 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	lc "github.com/Jackd4w/GoGEM/pkg/LinkCheck"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+)
+
+// linkcheckCmd represents the linkcheck command
+var linkcheckCmd = &cobra.Command{
+	Use:   "linkcheck",
+	Short: "Check your deployed Wiki for broken links and missing files",
+	Long: `Crawls every page of your team on the iGEM Wiki, starting at https://[year].igem.org/Team:[Teamname], and checks every link and file on it.
+	Reports pages and files that are not found (i.e. ?action=raw links to css pages that were never created), empty pages, links leaving igem.org,
+	references to your WordPress Page (if specified with -w) and files that are loaded over http.
+	Only the content of your pages is checked, not the iGEM navigation. No login is needed.
+	Usage: GoGEM linkcheck -y [year] -t "[Teamname]" -w "[WP URL]" -r "[Report File]"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		prof := activeProfile()
+		if prof == nil {
+			return
+		}
+
+		println(fmt.Sprintf("Checking %s/Team:%s", prof.BaseURL, teamname))
+		reports, err := lc.Check(prof.BaseURL, teamname, wpurl, insecure, timeout)
+		if err != nil {
+			out.EmitError("linkcheck", err.Error())
+			return
+		}
+
+		for _, report := range reports {
+			if len(report.Findings) == 0 && !out.Structured() {
+				continue
+			}
+			message := fmt.Sprintf("%s (%d)", report.URL, report.Status)
+			for _, finding := range report.Findings {
+				message += "\n  " + finding.Kind + ": " + finding.Message
+			}
+			out.Emit(out.Event{Type: out.PageChecked, Message: message, URL: report.URL, Data: report})
+		}
+
+		count := lc.Count(reports)
+		println(fmt.Sprintf("Checked %d pages, %d findings", len(reports), count))
+
+		if report_file != "" {
+			content, err := json.MarshalIndent(reports, "", "  ")
+			if err == nil {
+				err = ioutil.WriteFile(report_file, content, 0644)
+			}
+			if err != nil {
+				out.EmitError("linkcheck", err.Error())
+			}
+		}
+
+		if strict && count > 0 {
+			out.Flush()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(linkcheckCmd)
+
+	linkcheckCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year(required)")
+	linkcheckCmd.MarkFlagRequired("year")
+	linkcheckCmd.Flags().StringVarP(&teamname, "teamname", "t", "", "Teamname(required)")
+	linkcheckCmd.MarkFlagRequired("teamname")
+	linkcheckCmd.Flags().StringVarP(&wpurl, "wpurl", "w", "", "WordPress URL, references to it are reported")
+	linkcheckCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Ignores HTTPS Certificate warnings")
+	linkcheckCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds for every checked link")
+	linkcheckCmd.Flags().StringVarP(&report_file, "report", "r", "", "Writes the report of all pages as JSON to this file")
+	linkcheckCmd.Flags().BoolVarP(&strict, "strict", "s", false, "Exits with status 1 if anything was found")
+}
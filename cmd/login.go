@@ -0,0 +1,107 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+)
+
+var lifetime int
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in once for all following commands",
+	Long: `Logs in to the iGEM Servers and stores the session in your cache directory, readable only by you.
+	All following commands reuse the session instead of asking for your password and logging in again, until it expires or you log out.
+	An expired session is renewed automatically by the next command.
+	Usage: GoGEM login -u "[Username]" -y [year] -e [Lifetime in hours]`,
+	Run: func(cmd *cobra.Command, args []string) {
+		prof := activeProfile()
+		if prof == nil {
+			return
+		}
+		if err := h.ForgetSession(username, prof.BaseURL); err != nil { // Always start with a fresh session
+			println(err.Error())
+			return
+		}
+		session := login()
+		if session == nil {
+			return
+		}
+		if err := session.Persist(time.Duration(lifetime) * time.Hour); err != nil {
+			println("Could not store the session: " + err.Error())
+			session.Logout()
+			return
+		}
+		expiry, _ := h.SessionExpiry(username, session.BaseURL())
+		println("Session stored until " + expiry.Format(time.RFC1123))
+	},
+}
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "End the session stored by login",
+	Long: `Logs out from the iGEM Servers and removes the session stored by login.
+	Usage: GoGEM logout -u "[Username]" -y [year]`,
+	Run: func(cmd *cobra.Command, args []string) {
+		prof := activeProfile()
+		if prof == nil {
+			return
+		}
+		if _, ok := h.SessionExpiry(username, prof.BaseURL); !ok {
+			println("No stored session found for " + prof.BaseURL)
+			return
+		}
+		session, err := h.NewHandler(year, timeout, username, func() (string, error) {
+			return "", fmt.Errorf("stored session expired, nothing to log out")
+		}, teamname, offset, prof.LoginURL, prof.LogoutURL, prof.PrefixURL, prof.BaseURL)
+		if err != nil {
+			println(err.Error())
+			h.ForgetSession(username, prof.BaseURL)
+			return
+		}
+		if err := session.Logout(); err != nil {
+			println(err.Error())
+			return
+		}
+		println("Logged out")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+
+	loginCmd.Flags().StringVarP(&username, "username", "u", "", "Username(required)")
+	loginCmd.MarkFlagRequired("username")
+	loginCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year(required)")
+	loginCmd.MarkFlagRequired("year")
+	loginCmd.Flags().StringVarP(&password, "password", "p", "", "Password")
+	loginCmd.Flags().IntVarP(&lifetime, "expiry", "e", 12, "Lifetime of the stored session in hours")
+	loginCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+
+	logoutCmd.Flags().StringVarP(&username, "username", "u", "", "Username(required)")
+	logoutCmd.MarkFlagRequired("username")
+	logoutCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year(required)")
+	logoutCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+}
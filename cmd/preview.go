@@ -0,0 +1,95 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+	pv "github.com/Jackd4w/GoGEM/pkg/Preview"
+)
+
+var preview_addr string
+var preview_header string
+var preview_footer string
+var preview_prepare bool
+
+// previewCmd represents the preview command
+var previewCmd = &cobra.Command{
+	Use:   "preview [directory]",
+	Short: "Preview your Wiki on your PC before uploading it",
+	Long: `Serves a project on localhost, the way it would look on the iGEM Wiki.
+	Every page is wrapped in an approximation of the iGEM navigation and footer, which can be replaced with your own HTML (--header, --footer).
+	Template calls are resolved with the files in the templates folder of the project, ?action=raw links are answered with the local stylesheets and scripts.
+	The page in your browser reloads whenever a file in the directory changes.
+	With --prepare the directory can be a project saved with fetchWP, it is prepared like for the upload (without uploading anything) after every change.
+	The directory itself is not changed.
+	Usage: GoGEM preview "[Directory]" -t "[Teamname]" -o "[offset]" -a "localhost:8080" --prepare`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prof := activeProfile()
+		if prof == nil {
+			return
+		}
+		templates := teamTemplates(prof)
+
+		opts := pv.Options{Root: args[0], Teamname: teamname, Offset: offset, Templates: templates, Addr: preview_addr}
+		for _, replacement := range []struct {
+			file string
+			html *string
+		}{{preview_header, &opts.Header}, {preview_footer, &opts.Footer}} { // Header and footer may be the same file
+			if replacement.file == "" {
+				continue
+			}
+			content, err := ioutil.ReadFile(replacement.file)
+			if err != nil {
+				out.EmitError("preview", err.Error())
+				return
+			}
+			*replacement.html = string(content)
+		}
+		if preview_prepare {
+			opts.Prepare = func(dir string) error {
+				if err := fh.PrepareFiles(templates, dir, prof.MathJaxURL, nil, false); err != "" {
+					return fmt.Errorf("preparing failed:\n%s", strings.TrimSpace(err)) // The preview keeps the last prepared copy
+				}
+				return nil
+			}
+		}
+
+		if err := pv.Serve(opts); err != nil {
+			out.EmitError("preview", err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+
+	previewCmd.Flags().IntVarP(&year, "year", "y", 2021, "Year")
+	previewCmd.Flags().StringVarP(&teamname, "teamname", "t", "", "Teamname(required)")
+	previewCmd.MarkFlagRequired("teamname")
+	previewCmd.Flags().StringVarP(&offset, "offset", "o", "", "Offset from your Teams Namespace root")
+	previewCmd.Flags().StringVarP(&preview_addr, "addr", "a", "localhost:8080", "Address the preview is served on")
+	previewCmd.Flags().StringVar(&preview_header, "header", "", "HTML file replacing the approximation of the iGEM navigation")
+	previewCmd.Flags().StringVar(&preview_footer, "footer", "", "HTML file replacing the approximation of the iGEM footer")
+	previewCmd.Flags().BoolVarP(&preview_prepare, "prepare", "P", false, "Prepares a copy of the directory like upload does, after every change")
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+	p "github.com/Jackd4w/GoGEM/pkg/Profile"
+	cc "github.com/Jackd4w/GoGEM/pkg/checkCriteria"
+)
+
+var profile_file string
+var profile *p.Profile
+
+/*
+	Returns the profile of the year selected with --year, loaded once per run.
+	URLs, Order, Criteria and the URLs of the servers in the config file still override the profile, so older config files keep working.
+	Returns nil if there is no profile for the year, the reason has already been printed.
+*/
+func activeProfile() *p.Profile {
+	if profile != nil && profile.Year == year {
+		return profile
+	}
+
+	var files []string
+	if profile_file != "" {
+		files = append(files, profile_file)
+	}
+	loaded, err := p.Load(year, files...)
+	if err != nil {
+		if err.Error() == "noProfile" {
+			dir, _ := p.UserDir()
+			out.EmitError("profile", fmt.Sprintf("No profile for %d, GoGEM comes with profiles for %v. Create %s or pass one with --profile", year, p.Bundled(), filepath.Join(dir, fmt.Sprintf("%d.json", year))))
+			return nil
+		}
+		out.EmitError("profile", err.Error())
+		return nil
+	}
+
+	if config.LOGINURL != "" {
+		loaded.LoginURL = config.LOGINURL
+	}
+	if config.LOGOUTURL != "" {
+		loaded.LogoutURL = config.LOGOUTURL
+	}
+	if config.PREFIXPAGEURL != "" {
+		loaded.PrefixURL = config.PREFIXPAGEURL
+	}
+	if config.MATHJAXURL != "" {
+		loaded.MathJaxURL = config.MATHJAXURL
+	}
+	if len(config.URLS) > 0 { // The config does not distinguish between medals and awards
+		loaded.RequiredPages = make(map[string]string)
+		loaded.AwardPages = nil
+		for criterion, page := range config.URLS {
+			if page != "#" {
+				loaded.RequiredPages[criterion] = page
+			}
+		}
+	}
+	if len(config.URLORDER) > 0 {
+		loaded.Order = config.URLORDER
+	}
+	for criterion, requirements := range config.CRITERIA {
+		if loaded.Criteria == nil {
+			loaded.Criteria = make(map[string]cc.Requirements)
+		}
+		loaded.Criteria[criterion] = requirements
+	}
+	for font, src := range config.FONTS {
+		if loaded.Fonts == nil {
+			loaded.Fonts = make(map[string]string)
+		}
+		loaded.Fonts[font] = src
+	}
+
+	profile = loaded
+	return profile
+}
+
+/*
+	Templates of the team for preparing the pages, the team template of every page is chosen by its page group in the profile
+*/
+func teamTemplates(prof *p.Profile) fh.Templates {
+	return fh.Templates{Team: teamname, Page: func(filename string) string {
+		return prof.Template(filename, teamname)
+	}}
+}
@@ -20,10 +20,11 @@ import (
 	"fmt"
 	"syscall"
 
-	h "github.com/Jackd4w/GoGEM/pkg/Handler"
-
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
 )
 
 // purgeCmd represents the purge command
@@ -32,64 +33,42 @@ var purgeCmd = &cobra.Command{
 	Short: "CAUTION!! DESTRUCTIVE ACTION! Purge your Wiki Pages from the Server",
 	Long: `CAUTION!! DESTRUCTIVE ACTION! Purge your Wiki from the iGEM Servers.
 	Files can not be deleted, but pages can be overwritten with no content. Usefull for cleaning up before setting up your actual Wiki.
-	THIS IS A DESTRUCTIVE ACTION, you will be required to re enter your password.
+	THIS IS A DESTRUCTIVE ACTION, you will be required to re enter your password (unless confirmed by --yes).
 	Usage: GoGEM purge -u "[Username]" -y [Wiki Year] -t "[Teamname]" -o "[Offset]"`,
 	Run: func(cmd *cobra.Command, args []string) {
-		println("This is a DESTRUCTIVE ACTION, you will be required to re enter your password after you logged in. If you want to abort please hit 'Ctrl + C' on your keyboard or close the shell")
-		fmt.Print("Enter Password: ")
-		bytePassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			println(err.Error())
-			return
+		if !yes { // Needed to confirm the deletion, --yes uses the stored session
+			println("This is a DESTRUCTIVE ACTION, you will be required to re enter your password after you logged in. If you want to abort please hit 'Ctrl + C' on your keyboard or close the shell")
+			if !requirePassword() {
+				return
+			}
 		}
-		println("")
-		password = string(bytePassword)
 
 		// Establish connection with iGEM Servers
-		println("Logging in...")
-		session, err := h.NewHandler(year, timeout, username, password, teamname, offset, config.LOGINURL, config.LOGOUTURL, config.PREFIXPAGEURL)
-		if err != nil {
-			if err.Error() == "loginFailed" {
-				println("Login failed, please try again")
-				return
-			}
-			println(err.Error())
+		session := login()
+		if session == nil {
 			return
 		}
-		defer session.Logout()
-		println("Logged in")
+		defer session.Close()
 
-		println(fmt.Sprintf("Getting all Pages with prefix %s/%s from https://%d.igem.org", teamname, offset, year))
+		println(fmt.Sprintf("Getting all Pages with prefix %s/%s from %s", teamname, offset, session.BaseURL()))
 		pages, err := session.GetAllPages()
 		if err != nil {
-			println(err.Error())
+			out.EmitError("purge", err.Error())
 			return
 		}
 		for _, page := range pages {
-			println(fmt.Sprintf("https://%d.igem.org%s", year, page))
+			pageFound(session, page)
 			// session.DeletePage(page)
 		}
-		println("")
-		println("-------------------------------------------------------------")
-		println("ARE YOU SURE YOU WANT TO DELETE ALL PAGES ABOVE?")
-		println("THIS ACTION CAN NOT BE UNDONE!")
-		println("-------------------------------------------------------------")
-		print("Re-Enter your password to continue:")
-		reEnteredPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			println(err.Error())
+		if !confirmDeletion() {
 			return
 		}
-		println("")
-		if !bytes.Equal(reEnteredPassword, bytePassword) {
-			println("Password Mismatch, aborting...")
+		if backup && !createBackup(session) {
 			return
 		}
-		println("")
 		println("Purging...")
 		for _, page := range pages {
-			println(page)
-			session.DeletePage(page)
+			deletePage(session, page)
 		}
 		println("")
 		println("Purge complete, logging out")
@@ -110,6 +89,9 @@ func init() {
 	purgeCmd.Flags().StringVarP(&password, "password", "p", "", "Password")
 	purgeCmd.Flags().StringVarP(&offset, "offset", "o", "", "Offset from your Teams Namespace root")
 	purgeCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+	purgeCmd.Flags().BoolVarP(&yes, "yes", "Y", false, "Confirms the deletion without re-entering the password, for use in scripts")
+	purgeCmd.Flags().BoolVarP(&backup, "backup", "b", false, "Creates a backup of the Wiki before purging")
+	purgeCmd.Flags().StringVarP(&backup_dir, "backup-dir", "d", "", "Backup Directory; Standard: backup-[Teamname]-[Timestamp] in the current working directory")
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -120,3 +102,56 @@ func init() {
 	// is called directly, e.g.:
 	// purgeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+/*
+	Asks the user to confirm the deletion of the pages listed before by re-entering the password, unless confirmed beforehand by --yes.
+	Returns false if the deletion should be aborted.
+*/
+func confirmDeletion() bool {
+	if yes {
+		println("Deletion confirmed by --yes")
+		return true
+	}
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		println("Not running in a terminal, confirm the deletion with --yes. Aborting...")
+		return false
+	}
+	println("")
+	println("-------------------------------------------------------------")
+	println("ARE YOU SURE YOU WANT TO DELETE ALL PAGES ABOVE?")
+	println("THIS ACTION CAN NOT BE UNDONE!")
+	println("-------------------------------------------------------------")
+	print("Re-Enter your password to continue:")
+	reEnteredPassword, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		println(err.Error())
+		return false
+	}
+	println("")
+	if !bytes.Equal(reEnteredPassword, []byte(password)) {
+		println("Password Mismatch, aborting...")
+		return false
+	}
+	println("")
+	return true
+}
+
+/*
+	Lists a page that is going to be deleted
+*/
+func pageFound(session *h.Handler, page string) {
+	out.Emit(out.Event{Type: out.PageFound, Message: session.BaseURL() + page, URL: page})
+}
+
+/*
+	Deletes the page and reports the result
+*/
+func deletePage(session *h.Handler, page string) error {
+	err := session.DeletePage(page)
+	if err != nil {
+		out.Emit(out.Event{Type: out.Error, Category: "delete", Message: "Error " + err.Error() + " deleting page: " + page, URL: page})
+		return err
+	}
+	out.Emit(out.Event{Type: out.PageDeleted, Message: page, URL: page})
+	return nil
+}
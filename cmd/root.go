@@ -22,6 +22,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/spf13/viper"
+
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+	cc "github.com/Jackd4w/GoGEM/pkg/checkCriteria"
 )
 
 var cfgFile string
@@ -37,16 +40,22 @@ var force bool
 var clean bool
 var insecure bool
 var redirect bool
+var yes bool
+var credentials_file string
+var output string
 
 type Config struct {
-	URLS            map[string]string `mapstructure:"urls"`
-	URLORDER        []string          `mapstructure:"order"`
-	FONTS           map[string]string `mapstructure:"fonts"`
-	CUSTOMREDIRECTS map[string]string `mapstructure:"customredirects"`
-	LOGINURL        string            `mapstructure:"loginurl"`
-	LOGOUTURL       string            `mapstructure:"logouturl"`
-	PREFIXPAGEURL   string            `mapstructure:"prefixurl"`
-	MATHJAXURL      string            `mapstructure:"mathjaxurl"`
+	URLS            map[string]string          `mapstructure:"urls"`
+	URLORDER        []string                   `mapstructure:"order"`
+	FONTS           map[string]string          `mapstructure:"fonts"`
+	CUSTOMREDIRECTS map[string]string          `mapstructure:"customredirects"`
+	LOGINURL        string                     `mapstructure:"loginurl"`
+	LOGOUTURL       string                     `mapstructure:"logouturl"`
+	PREFIXPAGEURL   string                     `mapstructure:"prefixurl"`
+	MATHJAXURL      string                     `mapstructure:"mathjaxurl"`
+	CREDENTIALSFILE string                     `mapstructure:"credentialsfile"`
+	PASSWORDCOMMAND string                     `mapstructure:"passwordcommand"`
+	CRITERIA        map[string]cc.Requirements `mapstructure:"criteria"`
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -56,12 +65,17 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return out.SetFormat(output)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	err := rootCmd.Execute()
+	out.Flush()
+	cobra.CheckErr(err)
 }
 
 func init() {
@@ -72,6 +86,9 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./GoGEM.json)")
+	rootCmd.PersistentFlags().StringVar(&output, "output", "text", "output format: text, json or ndjson (machine readable events on stdout)")
+	rootCmd.PersistentFlags().StringVar(&profile_file, "profile", "", "profile overriding the competition profile of the year (default is GoGEM/profiles/[year].json in your config directory, if it exists)")
+	rootCmd.PersistentFlags().StringVar(&credentials_file, "credentials", "", "credentials file mapping usernames to passwords (default is GoGEM/credentials.json in your config directory)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
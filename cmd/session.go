@@ -0,0 +1,97 @@
+/*
+Copyright © 2021 Kai Kabuth <kai.kabuth@stud.tu-darmstadt.de>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	cr "github.com/Jackd4w/GoGEM/pkg/Credentials"
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+)
+
+/*
+	Resolves the password of the user, the first source that has one wins:
+	--password flag, GOGEM_PASSWORD environment variable, credentials file, password command from the config, interactive prompt.
+*/
+func resolvePassword() (string, error) {
+	return cr.Resolve(username,
+		cr.Static(password),
+		cr.Env("GOGEM_PASSWORD"),
+		cr.File(credentialsFile()),
+		cr.Command(config.PASSWORDCOMMAND),
+		cr.Prompt{},
+	)
+}
+
+/*
+	Path of the credentials file: --credentials flag, CredentialsFile from the config or GoGEM/credentials.json in the users config directory
+*/
+func credentialsFile() string {
+	if credentials_file != "" {
+		return credentials_file
+	}
+	if config.CREDENTIALSFILE != "" {
+		return config.CREDENTIALSFILE
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "GoGEM", "credentials.json")
+}
+
+/*
+	Resolves the password before it is needed, for commands that ask for it again as a confirmation
+	Returns false if there is no password, the reason has already been printed.
+*/
+func requirePassword() bool {
+	var err error
+	if password, err = resolvePassword(); err != nil {
+		out.EmitError("login", err.Error())
+		return false
+	}
+	return true
+}
+
+/*
+	Establishes the connection with the iGEM Servers, used by every command that needs to be logged in.
+	A session stored by GoGEM login is reused, the password is only resolved if a login is necessary.
+	Returns nil if the login failed, the reason has already been printed.
+*/
+func login() *h.Handler {
+	prof := activeProfile()
+	if prof == nil {
+		return nil
+	}
+	println("Logging in...")
+	session, err := h.NewHandler(year, timeout, username, func() (string, error) {
+		var err error
+		password, err = resolvePassword()
+		return password, err
+	}, teamname, offset, prof.LoginURL, prof.LogoutURL, prof.PrefixURL, prof.BaseURL)
+	if err != nil {
+		if err.Error() == "loginFailed" {
+			out.EmitError("login", "Login failed, please try again")
+			return nil
+		}
+		out.EmitError("login", err.Error())
+		return nil
+	}
+	println("Logged in")
+	return session
+}
@@ -17,21 +17,26 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
 
+	d "github.com/Jackd4w/GoGEM/pkg/Diff"
 	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
 	wp "github.com/Jackd4w/GoGEM/pkg/GoStatic"
 	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
 	r "github.com/Jackd4w/GoGEM/pkg/Redirect"
 )
 
 var errors []string
+var prune bool
+var strict_links bool
+var templates_dir string
 
 // uploadCmd represents the upload command
 var uploadCmd = &cobra.Command{
@@ -42,38 +47,37 @@ var uploadCmd = &cobra.Command{
 	If you want to clone your Wiki to https://2021.igem.org/Team:TU_Darmstadt/test/[...] then the command would be:
 	GoGEM upload -u "[Your Username]" -y 2021 -t "TU_Darmstadt" -w "[Your WP Wiki]" -o "test".
 	It is important that you add the used protocol for your WP-Page (i.e. http or https).
+	With --prune all pages on the Wiki that do not exist locally anymore get blanked, after you confirmed the list of these pages.
+	Before the pages are uploaded the links in the prepared files are checked, with --strict-links the upload is aborted if any link is broken.
+	The header and footer of your WordPress theme are uploaded as the templates Template:[Teamname]/header and Template:[Teamname]/footer, and are called by every page sharing them.
+	The HTML files in the folder given with --templates are uploaded as Template:[Teamname]/[name] as well, a file named [Teamname].html replaces your team template.
+	With --offline your WordPress Page is not cloned again, the pages are prepared from the responses stored by the last crawl (see --cache-dir).
 	Usage: GoGEM upload -u "[Username]" -y [year] -t "[Teamname]" -w "[WP URL]" -o "[offset]"`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		// Get necessary data
 
-		println(fmt.Sprintf("Upload %s for %s to https://%d.igem.org/Team:%s", wpurl, username, year, teamname))
-		fmt.Print("Enter Password: ")
-		bytePassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			println(err.Error())
+		if prune && !yes && !requirePassword() { // Needed to confirm the pruning
 			return
 		}
-		println("")
-		password = string(bytePassword)
+
 		// Establish connection with iGEM Servers
-		println("Logging in...")
-		session, err := h.NewHandler(year, timeout, username, password, teamname, offset, config.LOGINURL, config.LOGOUTURL, config.PREFIXPAGEURL)
-		if err != nil {
-			if err.Error() == "loginFailed" {
-				println("Login failed, please try again")
-				return
-			}
-			println(err.Error())
+		session := login()
+		if session == nil {
 			return
 		}
-		defer session.Logout()
-		println("Logged in")
+		defer session.Close()
+		prof := activeProfile() // Already loaded by login
+		println(fmt.Sprintf("Upload %s for %s to %s/Team:%s", wpurl, username, session.BaseURL(), teamname))
 		println("Starting time: " + time.Now().String())
 
+		if backup && !createBackup(session) {
+			return
+		}
+
 		if redirect {
 			println("Creating redirects...")
-			r.CreateUppercaseRedirects(config.URLS, session)
+			r.CreateUppercaseRedirects(prof.URLs(), session)
 			for source, target := range config.CUSTOMREDIRECTS {
 				fmt.Printf("Creating redirect from %s to %s \n", source, target)
 				r.CreateRedirect(source, target, session)
@@ -81,23 +85,41 @@ var uploadCmd = &cobra.Command{
 		}
 		// Clone WordPress Page
 		println("Cloning WordPress Page...")
-		project_path, err := wp.GoStatic(wpurl, "", config.FONTS, insecure)
+		project_path, err := wp.GoStatic(wpurl, "", crawlOptions(prof))
 		if err != nil {
-			println(err.Error())
+			out.EmitError("clone", err.Error())
 			errors = append(errors, err.Error())
 		}
 		defer cleanUp(project_path)
 		if !clean {
 			println("Temporary files are not deleted")
 		}
+		if templates_dir != "" && !copyTemplates(templates_dir, project_path) {
+			return
+		}
 		println("Cloning successfull, begining upload...")
 		// Prepare Files and Upload them
 
-		if err := fh.PrepFilesForIGEM(teamname, project_path, config.MATHJAXURL, session); err != "" {
+		if err := fh.PrepareFiles(teamTemplates(prof), project_path, prof.MathJaxURL, session, true); err != "" {
 			errorlist := strings.Split(err, "\n")
 			errors = append(errors, errorlist...)
 		}
-		if len(errors) > 0 {
+		if !validatePrepared(project_path, session) {
+			println("Upload aborted, the prepared pages have broken links. No page has been uploaded")
+			return
+		}
+		if err := fh.UploadTemplates(project_path, session); err != nil { // Before the pages, which call them
+			errors = append(errors, err.Error())
+		}
+		if err := fh.UploadPages(project_path, session); err != nil {
+			errors = append(errors, err.Error())
+		}
+		if prune {
+			pruneOrphans(project_path, session)
+		}
+		if out.Structured() {
+			out.Emit(out.Event{Type: out.Summary, Data: map[string]interface{}{"errors": errors}})
+		} else if len(errors) > 0 {
 			println("---------------------------------------------------------")
 			println("Error summary:")
 			for _, err := range errors {
@@ -127,8 +149,98 @@ func init() {
 	uploadCmd.Flags().BoolVarP(&force, "force", "f", false, "Forces upload")
 	uploadCmd.Flags().BoolVarP(&clean, "clean", "c", true, "Cleanup the temporary files")
 	uploadCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Ignores HTTPS Certificate warnings")
+	uploadCmd.Flags().BoolVar(&script_assets, "script-assets", false, "Finds files referenced in the scripts of your WordPress Page, uploads them and replaces them in the scripts")
+	uploadCmd.Flags().StringVar(&cache_dir, "cache-dir", "", "Directory of the stored responses of the crawl; Standard: GoGEM/crawl in your cache directory")
+	uploadCmd.Flags().BoolVar(&offline, "offline", false, "Prepares the pages from the stored responses of the last crawl, without cloning your WordPress Page again")
+	addCrawlFlags(uploadCmd)
 	uploadCmd.Flags().BoolVarP(&redirect, "redirect", "r", false, "Creates redirects from upper to lowercase, and CustomRedirects if specified")
 	uploadCmd.Flags().IntVarP(&timeout, "timeout", "T", 60, "Timeout in seconds")
+	uploadCmd.Flags().BoolVarP(&prune, "prune", "P", false, "Blanks all pages on the Wiki that do not exist in your WordPress Page anymore, after confirmation")
+	uploadCmd.Flags().BoolVarP(&yes, "yes", "Y", false, "Confirms the deletion of pruned pages without re-entering the password, for use in scripts")
+	uploadCmd.Flags().BoolVarP(&backup, "backup", "b", false, "Creates a backup of the Wiki before uploading")
+	uploadCmd.Flags().BoolVarP(&strict_links, "strict-links", "L", false, "Aborts the upload before any page is uploaded, if the prepared pages have broken links")
+	uploadCmd.Flags().StringVarP(&templates_dir, "templates", "m", "", "Folder with your own templates, they replace the ones generated from your WordPress theme")
+	uploadCmd.Flags().StringVarP(&backup_dir, "backup-dir", "d", "", "Backup Directory; Standard: backup-[Teamname]-[Timestamp] in the current working directory")
+}
+
+/*
+	Blanks all pages on the Wiki that are not part of the uploaded project anymore (i.e. after renaming a WordPress page).
+	The pages are listed and have to be confirmed like in purge.
+*/
+func pruneOrphans(project_path string, session *h.Handler) {
+	println(fmt.Sprintf("Searching for pages with prefix %s/%s that do not exist locally anymore...", teamname, offset))
+	orphans, err := d.Orphans(project_path, session)
+	if err != nil {
+		out.EmitError("prune", err.Error())
+		errors = append(errors, err.Error())
+		return
+	}
+	if len(orphans) == 0 {
+		println("No orphaned pages found")
+		return
+	}
+	for _, page := range orphans {
+		pageFound(session, page)
+	}
+	if !confirmDeletion() {
+		return
+	}
+	println("Pruning...")
+	for _, page := range orphans {
+		if err := deletePage(session, page); err != nil {
+			errors = append(errors, "Error "+err.Error()+" pruning page: "+page)
+		}
+	}
+}
+
+/*
+	Checks the links of the prepared files before their pages are uploaded, every problem is reported as error.
+	Returns false if the upload should be aborted because of --strict-links.
+*/
+func validatePrepared(project_path string, session *h.Handler) bool {
+	println("Checking links of the prepared pages...")
+	problems, err := fh.Validate(project_path, wpurl, session)
+	if err != nil {
+		out.EmitError("validation", err.Error())
+		errors = append(errors, err.Error())
+		return !strict_links
+	}
+	for _, problem := range problems {
+		message := problem.File + ": " + problem.Message
+		out.Emit(out.Event{Type: out.Error, Category: "validation", Message: message, Source: problem.File, Data: problem})
+		errors = append(errors, message)
+	}
+	if len(problems) == 0 {
+		println("No broken links found")
+	}
+	return len(problems) == 0 || !strict_links
+}
+
+/*
+	Copies the templates of the user into the cloned project, templates with the same name as the generated ones replace them
+*/
+func copyTemplates(dir, project_path string) bool {
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(project_path, "templates"), 0755)
+	}
+	for _, entry := range entries {
+		if err != nil {
+			break
+		}
+		if entry.IsDir() {
+			continue
+		}
+		var content []byte
+		if content, err = ioutil.ReadFile(filepath.Join(dir, entry.Name())); err == nil {
+			err = ioutil.WriteFile(filepath.Join(project_path, "templates", entry.Name()), content, 0644)
+		}
+	}
+	if err != nil {
+		out.EmitError("templates", err.Error())
+		return false
+	}
+	return true
 }
 
 func cleanUp(project_dir string) {
@@ -0,0 +1,161 @@
+package GoGEMbackup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+)
+
+/*
+Index of a backup, gets written as index.json into the backup directory.
+All file paths are relative to the backup directory.
+*/
+type Index struct {
+	Created string   `json:"created"`
+	Wiki    string   `json:"wiki"`
+	Team    string   `json:"team"`
+	Pages   []Page   `json:"pages"`
+	Images  []Image  `json:"images"`
+	Failed  []string `json:"failed,omitempty"`
+}
+
+type Page struct {
+	URL    string   `json:"url"`    // Page url relative to the wiki root, i.e. /Team:TU_Darmstadt/software
+	File   string   `json:"file"`   // Raw wikitext of the page
+	Images []string `json:"images"` // Images referenced by the page
+}
+
+type Image struct {
+	URL  string `json:"url"`
+	File string `json:"file"`
+}
+
+var imageRegEx = regexp.MustCompile(`(https?://[0-9]+\.igem\.org)?/wiki/images/[^"'\s)<>]+`) // Uploaded media files always live below /wiki/images/
+
+/*
+Creates a snapshot of everything that is live on the wiki for the team of the handler.
+Every page under the team prefix and every team template is saved as raw wikitext, every referenced /wiki/images/ file is downloaded.
+Single pages or images that fail are recorded in the index and do not abort the backup.
+Returns the path to the backup directory.
+*/
+func Backup(path string, client *h.Handler) (string, error) {
+	var err error
+	if path == "" {
+		if path, err = os.Getwd(); err != nil { // Set path to current working directory
+			return "", err
+		}
+		path = filepath.Join(path, "backup-"+client.Teamname()+"-"+time.Now().Format("20060102-150405"))
+	}
+
+	index := Index{
+		Created: time.Now().Format(time.RFC3339),
+		Wiki:    client.BaseURL(),
+		Team:    client.Teamname(),
+	}
+
+	pages, err := client.GetAllPages()
+	if err != nil {
+		return "", err
+	}
+	templates, err := client.GetAllTemplates()
+	if err != nil {
+		return "", err
+	}
+	pages = append(pages, templates...)
+
+	images := make(map[string]bool) // Images are shared between pages, only download them once
+
+	for _, pageurl := range pages {
+		println("Saving page: " + pageurl)
+		content, err := client.GetRawPage(pageurl)
+		if err != nil {
+			println("Error " + err.Error() + " saving page: " + pageurl)
+			index.Failed = append(index.Failed, pageurl)
+			continue
+		}
+
+		file := pageFile(pageurl)
+		if err := writeFile(filepath.Join(path, file), []byte(content)); err != nil {
+			return "", err
+		}
+
+		page := Page{URL: pageurl, File: file}
+		for _, image := range findImages(content) {
+			page.Images = append(page.Images, image)
+			images[image] = true
+		}
+		index.Pages = append(index.Pages, page)
+	}
+
+	for image := range images {
+		println("Saving image: " + image)
+		file := imageFile(image)
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(path, file)), 0755); err != nil {
+			return "", err
+		}
+		if err := client.DownloadFile(image, filepath.Join(path, file)); err != nil {
+			println("Error " + err.Error() + " saving image: " + image)
+			index.Failed = append(index.Failed, image)
+			continue
+		}
+		index.Images = append(index.Images, Image{URL: image, File: file})
+	}
+
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(filepath.Join(path, "index.json"), indexJSON); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+/*
+Finds all references to uploaded media files in the wikitext, returns them relative to the wiki root
+*/
+func findImages(content string) []string {
+	var images []string
+	found := make(map[string]bool)
+	for _, link := range imageRegEx.FindAllString(content, -1) {
+		link = link[strings.Index(link, "/wiki/images/"):] // Strip the host, the files are downloaded from the wiki of the handler
+		if !found[link] {
+			found[link] = true
+			images = append(images, link)
+		}
+	}
+	return images
+}
+
+/*
+Maps a page url to a file inside the backup, i.e. /Team:TU_Darmstadt/css/style -> pages/Team_TU_Darmstadt/css/style.wiki
+Colons are replaced, as they are not allowed in Windows paths.
+*/
+func pageFile(pageurl string) string {
+	pageurl = strings.ReplaceAll(strings.TrimPrefix(h.UnescapePageURL(pageurl), "/"), ":", "_")
+	return filepath.Join("pages", filepath.FromSlash(pageurl)+".wiki")
+}
+
+/*
+Maps an image url to a file inside the backup, keeping the folder structure of the wiki, i.e. /wiki/images/a/ab/T--Team--x.png -> images/a/ab/T--Team--x.png
+*/
+func imageFile(imageurl string) string {
+	return filepath.Join("images", filepath.FromSlash(strings.TrimPrefix(imageurl, "/wiki/images/")))
+}
+
+/*
+Writes the file, creating all necessary parents
+*/
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,160 @@
+package GoGEMcredentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+/*
+A Provider is one source for the password of a user.
+If a provider can not provide a password it returns ErrNoPassword, so the next provider in the chain is asked.
+Any other error aborts the resolution, i.e. a credentials file that is readable by other users.
+*/
+type Provider interface {
+	Password(username string) (string, error)
+}
+
+var ErrNoPassword = errors.New("noPassword")
+
+/*
+Asks all providers in order for the password of the user, the first password found is returned
+*/
+func Resolve(username string, providers ...Provider) (string, error) {
+	for _, provider := range providers {
+		password, err := provider.Password(username)
+		if err == ErrNoPassword {
+			continue
+		}
+		return password, err
+	}
+	return "", errors.New("no password found, use --password, the GOGEM_PASSWORD environment variable, a credentials file or a password command")
+}
+
+/*
+Password given directly, i.e. by the --password flag
+*/
+type Static string
+
+func (s Static) Password(username string) (string, error) {
+	if s == "" {
+		return "", ErrNoPassword
+	}
+	return string(s), nil
+}
+
+/*
+Name of an environment variable that contains the password
+*/
+type Env string
+
+func (e Env) Password(username string) (string, error) {
+	password, ok := os.LookupEnv(string(e))
+	if !ok || password == "" {
+		return "", ErrNoPassword
+	}
+	return password, nil
+}
+
+/*
+Path to a JSON credentials file, mapping usernames to passwords: {"[Username]": "[Password]"}.
+The file has to be readable by the current user only, otherwise it is rejected (not checked on Windows, which has no unix permissions).
+*/
+type File string
+
+func (f File) Password(username string) (string, error) {
+	if f == "" {
+		return "", ErrNoPassword
+	}
+	info, err := os.Stat(string(f))
+	if os.IsNotExist(err) {
+		return "", ErrNoPassword
+	} else if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("credentials file %s is accessible by other users, please restrict its permissions (i.e. chmod 600)", f)
+	}
+
+	content, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+	credentials := make(map[string]string)
+	if err := json.Unmarshal(content, &credentials); err != nil {
+		return "", fmt.Errorf("credentials file %s is malformed: %s", f, err.Error())
+	}
+	password, ok := credentials[username]
+	if !ok || password == "" {
+		return "", ErrNoPassword
+	}
+	return password, nil
+}
+
+/*
+Command that prints the password to stdout, i.e. "pass show igem/{username}" for the pass password manager.
+The command is run by the shell (sh -c, cmd /C on Windows), so quoted arguments and pipes work as in a terminal.
+{username} gets replaced with the quoted username, the first line of the output is used as password.
+This way any secret store of the operating system can be plugged in.
+*/
+type Command string
+
+func (c Command) Password(username string) (string, error) {
+	if strings.TrimSpace(string(c)) == "" {
+		return "", ErrNoPassword
+	}
+	cmd := shellCommand(strings.ReplaceAll(string(c), "{username}", shellQuote(username)))
+	cmd.Stderr = os.Stderr // Password managers may ask for their own passphrase
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command failed: %s", err.Error())
+	}
+	password := strings.TrimRight(strings.SplitN(string(output), "\n", 2)[0], "\r")
+	if password == "" {
+		return "", ErrNoPassword
+	}
+	return password, nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+/*
+Quotes the username for the shell, so it is passed as one argument and can not run commands of its own
+*/
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+/*
+Asks the user for the password, only if the program runs in a terminal
+*/
+type Prompt struct{}
+
+func (p Prompt) Password(username string) (string, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return "", ErrNoPassword
+	}
+	fmt.Fprint(os.Stderr, "Enter Password: ")
+	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	println("")
+	if err != nil {
+		return "", err
+	}
+	return string(bytePassword), nil
+}
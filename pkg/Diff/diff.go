@@ -0,0 +1,311 @@
+package GoGEMdiff
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+)
+
+type State int
+
+const (
+	New       State = iota // Page only exists locally (or has been purged on the Wiki)
+	Changed                // Page exists on both sides, with different content
+	Unchanged              // Page exists on both sides, with the same content
+	Orphaned               // Page only exists on the Wiki
+)
+
+func (s State) String() string {
+	return [...]string{"new", "changed", "unchanged", "orphaned"}[s]
+}
+
+type PageDiff struct {
+	URL   string // Page url relative to the wiki root, i.e. /Team:TU_Darmstadt/software
+	File  string // Local file, empty for orphaned pages
+	State State
+	Local string // Prepared content of the local file
+	Live  string // Raw content of the page on the Wiki
+}
+
+const purgedPage = `<div class="purged-page-empty"></div>` // Content of pages blanked by purge
+
+/*
+Compares every page that will be uploaded from the prepared project in root with the raw content that is live on the Wiki.
+Pages on the Wiki below the team prefix (and offset of the handler) that do not exist locally are reported as orphaned.
+The result is sorted by page url.
+*/
+func Compare(root string, client *h.Handler) ([]PageDiff, error) {
+	var diffs []PageDiff
+
+	local, err := fh.PagePaths(root, client)
+	if err != nil {
+		return nil, err
+	}
+	live, err := client.GetAllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	for pageurl, file := range local {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		diff := PageDiff{URL: pageurl, File: file, Local: string(content)}
+
+		raw, err := client.GetRawPage(pageurl)
+		if err != nil && err.Error() != "pageNotFound" {
+			return nil, err
+		}
+		diff.Live = raw
+
+		if err != nil || normalize(raw) == "" || normalize(raw) == purgedPage {
+			diff.State = New
+		} else if normalize(raw) == normalize(diff.Local) {
+			diff.State = Unchanged
+		} else {
+			diff.State = Changed
+		}
+		diffs = append(diffs, diff)
+	}
+
+	for _, pageurl := range live {
+		if _, ok := local[h.UnescapePageURL(pageurl)]; !ok {
+			diffs = append(diffs, PageDiff{URL: pageurl, State: Orphaned})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].URL < diffs[j].URL
+	})
+	return diffs, nil
+}
+
+/*
+Returns all pages on the Wiki below the team prefix (and offset of the handler) that do not exist in the prepared project in root anymore.
+Pages that have already been blanked are skipped, so they do not show up again on every run.
+*/
+func Orphans(root string, client *h.Handler) ([]string, error) {
+	var orphans []string
+
+	local, err := fh.PagePaths(root, client)
+	if err != nil {
+		return nil, err
+	}
+	live, err := client.GetAllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pageurl := range live {
+		if _, ok := local[h.UnescapePageURL(pageurl)]; ok {
+			continue
+		}
+		raw, err := client.GetRawPage(pageurl)
+		if err == nil && (normalize(raw) == "" || normalize(raw) == purgedPage) {
+			continue
+		}
+		orphans = append(orphans, pageurl)
+	}
+	return orphans, nil
+}
+
+/*
+MediaWiki strips trailing whitespace and converts line endings when saving a page, so we do the same before comparing
+*/
+func normalize(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.TrimSpace(content)
+}
+
+/*
+Creates a unified diff from the live to the local content of the page, with the given number of context lines
+*/
+func (d PageDiff) Unified(context int) string {
+	return Unified(normalize(d.Live), normalize(d.Local), "live"+d.URL, "local"+d.URL, context)
+}
+
+/*
+------------------------------------------------------------------------------
+Unified diff
+------------------------------------------------------------------------------
+*/
+
+type op struct {
+	kind byte // ' ' for equal, '-' for deleted, '+' for inserted lines
+	line string
+}
+
+const maxDiffSize = 4000000 // Upper bound for the lcs table, bigger changes are shown as a full replacement
+
+/*
+Creates a unified diff of two texts, as known from diff -u and git diff
+*/
+func Unified(a, b, nameA, nameB string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	result := "--- " + nameA + "\n+++ " + nameB + "\n"
+
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until there are more than 2*context equal lines in a row
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			equal := end
+			for equal < len(ops) && ops[equal].kind == ' ' {
+				equal++
+			}
+			if equal == len(ops) || equal-end > 2*context {
+				break
+			}
+			end = equal
+		}
+
+		first := max(start-context, 0)
+		last := min(end+context, len(ops))
+
+		// Line numbers of the hunk in both texts
+		lineA, lineB := 1, 1
+		for _, o := range ops[:first] {
+			if o.kind != '+' {
+				lineA++
+			}
+			if o.kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		hunk := ""
+		for _, o := range ops[first:last] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+			hunk += string(o.kind) + o.line + "\n"
+		}
+		if countA == 0 { // Like diff -u, an empty range starts at the line before it, 0 for an empty text
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+		result += "@@ -" + hunkRange(lineA, countA) + " +" + hunkRange(lineB, countB) + " @@\n" + hunk
+
+		start = last
+	}
+	return result
+}
+
+/*
+Range of a hunk header, the count is left out for a single line like diff -u does
+*/
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+/*
+Calculates the line based edit script from a to b through the longest common subsequence.
+Common prefix and suffix are cut off first, as most changes are small compared to the page.
+*/
+func diffLines(a, b []string) []op {
+	var prefix, suffix []op
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, op{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]op{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	var middle []op
+	if len(a)*len(b) > maxDiffSize {
+		for _, line := range a {
+			middle = append(middle, op{'-', line})
+		}
+		for _, line := range b {
+			middle = append(middle, op{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) && j < len(b) {
+			if a[i] == b[j] {
+				middle = append(middle, op{' ', a[i]})
+				i++
+				j++
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				middle = append(middle, op{'-', a[i]})
+				i++
+			} else {
+				middle = append(middle, op{'+', b[j]})
+				j++
+			}
+		}
+		for ; i < len(a); i++ {
+			middle = append(middle, op{'-', a[i]})
+		}
+		for ; j < len(b); j++ {
+			middle = append(middle, op{'+', b[j]})
+		}
+	}
+
+	return append(append(prefix, middle...), suffix...)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,32 @@
+package GoGEMdiff
+
+import "testing"
+
+// The expected hunks are the output of diff -u for the same texts with a newline at the end
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"equal", "a\nb", "a\nb", 3, ""},
+		{"empty old side", "", "a\nb", 3, "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"empty new side", "a\nb", "", 3, "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"single line change", "a", "b", 3, "@@ -1 +1 @@\n-a\n+b\n"},
+		{"insertion without context", "1\n2\n3\n4\n5", "1\n2\n3\n4\n5\n6", 0, "@@ -5,0 +6 @@\n+6\n"},
+		{"merged context", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10", "1\nX\n3\n4\n5\n6\n7\n8\nY\n10", 3,
+			"@@ -1,10 +1,10 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+Y\n 10\n"},
+		{"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12", "1\nX\n3\n4\n5\n6\n7\n8\n9\n10\nY\n12", 1,
+			"@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n@@ -10,3 +10,3 @@\n 10\n-11\n+Y\n 12\n"},
+	}
+	for _, test := range tests {
+		want := test.want
+		if want != "" {
+			want = "--- a\n+++ b\n" + want
+		}
+		if got := Unified(test.a, test.b, "a", "b", test.context); got != want {
+			t.Errorf("%s: Unified =\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
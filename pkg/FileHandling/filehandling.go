@@ -1,12 +1,17 @@
 package GoGEMfilehandling
 
 import (
+	"html"
 	"io/ioutil"
+	neturl "net/url"
 	"os"
+	"path"
 	"regexp"
 	"strings"
 
 	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+	refs "github.com/Jackd4w/GoGEM/pkg/References"
 )
 
 var blacklist = make(map[string]string) // Creates a file wide blacklist for allready uploaded files, trying to reduce the request count to the iGEM Servers.
@@ -14,15 +19,40 @@ var blacklist = make(map[string]string) // Creates a file wide blacklist for all
 /*
 	Prepares pages for upload, and begins uploading the media files... (This dual purpose really gives me a headache, but iGEM randomizes the absolut url to the media files, and there is no other way than uploading to safely replace all links)
 	Cuts out all empty link references (these get created when converting a wp site to a static one and are remnants of the WP APIs)
-	Replaces the HTML DOCTYPE declaration with the standard iGEM template for the team (i.e. {{teamname}})
+	Replaces the HTML DOCTYPE declaration with the template of the team (i.e. {{teamname}}, as defined for the page group in the profile of the year)
+	Replaces the parts of the pages that are identical to a file in the templates folder (i.e. the header) with the call of the template
 	Removes all srcsets, these are good for optimization but dramatically increase the difficulty of uploading images to igem
+	Stylesheets get their files in url() uploaded and replaced as well, @import of local stylesheets requests their pages raw
+	Scripts get the files in their string literals found by the script analysis of GoStatic uploaded and replaced
 	Replace pageextensions: We can not easily upload JavaScript to the server and request it, because all our Files are just pages on the iGEM Wiki and the MIME-Type has to match.
 
 */
-func PrepFilesForIGEM(teamname, root, mathjax_url string, client *h.Handler) string {
+func PrepFilesForIGEM(templates Templates, root, mathjax_url string, client *h.Handler) string {
+	errors := PrepareFiles(templates, root, mathjax_url, client, true)
+
+	if err := UploadTemplates(root, client); err != nil { // Before the pages, which call them
+		return errors + err.Error()
+	}
+	if err := UploadPages(root, client); err != nil {
+		return errors + err.Error()
+	}
+	return errors
+}
+
+/*
+	Rewrites all files in root as PrepFilesForIGEM does, without uploading the pages.
+	If upload is false no media files are uploaded either, links to media files are only replaced if the file already exists on the iGEM Servers.
+	Without upload the client can be nil, then nothing is requested and the links to media files keep pointing to the local files.
+	This way the prepared output can be inspected or compared to the Wiki without changing anything on the Wiki.
+*/
+func PrepareFiles(templates Templates, root, mathjax_url string, client *h.Handler, upload bool) string {
 
 	// Get all files in the root directory
-	files, err := allFilesInDir(root)
+	files, err := projectFiles(root)
+	if err != nil {
+		return err.Error()
+	}
+	calls, err := templateCalls(root, templates.Team)
 	if err != nil {
 		return err.Error()
 	}
@@ -43,19 +73,30 @@ func PrepFilesForIGEM(teamname, root, mathjax_url string, client *h.Handler) str
 		}
 		var newContent = string(content) // Create newContent string from content byte array
 
-		if strings.Contains(filepath, ".css") { // If file is a css file
+		if strings.Contains(filepath, ".css") { // Stylesheets only reference files through url() and @import, relative to themselves
+			newContent, error := prepareStylesheet(newContent, filepath, root, client, upload)
+			if error != "" {
+				errors += error + "\n"
+				continue
+			}
+			if err := ioutil.WriteFile(filepath, []byte(newContent), 0644); err != nil {
+				return err.Error()
+			}
 			continue
 		}
+		if !isTemplate(root, filepath) {
+			newContent = replaceWithTemplateCalls(newContent, calls)
+		}
 		newContent = removeAllEmptyLinks(newContent)
 		newContent = removeObjects(newContent)
 		newContent = removeRemoveLinks(newContent)
-		newContent = replaceDoctypeWithTemplate(newContent, teamname)
+		newContent = replaceDoctypeWithTemplate(newContent, templates.PageTemplate(filepath))
 		newContent = removeSrcSet(newContent)
 		newContent = removeInlineWP(newContent)
 		newContent = replacePageExtensions(newContent, mathjax_url)
 
-		fileLinks := findAllFileLinks(newContent)
-		fileAssociations, error := fileUpload(fileLinks, root, client) // Output from FileUpload method takes fileLinks as input, and uploads all files to the iGEM Wiki
+		fileLinks := findAllFileLinks(newContent, filepath, root)
+		fileAssociations, error := uploadRelativeFiles(fileLinks, filepath, root, client, upload) // Uploads all files to the iGEM Wiki through fileUpload, and maps the links to their new urls
 		if error != "" {
 			errors += error + "\n"
 			continue
@@ -63,6 +104,14 @@ func PrepFilesForIGEM(teamname, root, mathjax_url string, client *h.Handler) str
 
 		newContent = replaceAllFileLinks(newContent, fileAssociations)
 
+		if path.Ext(filepath) == ".js" {
+			newContent, error = prepareScript(newContent, filepath, root, client, upload)
+			if error != "" {
+				errors += error + "\n"
+				continue
+			}
+		}
+
 		file, err = os.Create(file.Name())
 		if err != nil {
 			return err.Error()
@@ -72,14 +121,94 @@ func PrepFilesForIGEM(teamname, root, mathjax_url string, client *h.Handler) str
 			return err.Error()
 		}
 	}
-	println("File Upload: Done")
+	if upload {
+		println("File Upload: Done")
+	}
+	return errors
+}
+
+/*
+	Uploads all pages in root, the files have to be prepared with PrepareFiles beforehand.
+	The templates folder is left out, see UploadTemplates
+*/
+func UploadPages(root string, client *h.Handler) error {
+	files, err := projectFiles(root)
+	if err != nil {
+		return err
+	}
 	for _, filepath := range files {
+		if isTemplate(root, filepath) {
+			continue
+		}
 		err := pageUpload(filepath, client)
 		if err != nil {
-			return err.Error()
+			out.Emit(out.Event{Type: out.Error, Category: "pageUpload", Message: "Error " + err.Error() + " uploading page: " + filepath, Source: filepath})
+			return err
 		}
 	}
-	return errors
+	return nil
+}
+
+/*
+	Returns all pages in root that will be uploaded (without the templates), mapped from their url relative to the wiki root (i.e. /Team:TU_Darmstadt/css/style) to the local file
+*/
+func PagePaths(root string, client *h.Handler) (map[string]string, error) {
+	return pagePaths(root, client.PageURL)
+}
+
+/*
+	PagePaths without a handler, for the team and offset the pages would be uploaded to
+*/
+func TeamPagePaths(root, teamname, offset string) (map[string]string, error) {
+	return pagePaths(root, func(file, subOffset string) string {
+		return h.TeamPageURL(teamname, offset+subOffset, file) // Joined like the offsets of the handler
+	})
+}
+
+func pagePaths(root string, pageURL func(file, offset string) string) (map[string]string, error) {
+	files, err := projectFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]string)
+	for _, filepath := range files {
+		filename := filepath[strings.LastIndex(filepath, "/")+1:]
+		if isPage(filename) && !isTemplate(root, filepath) {
+			pages[pageURL(filename, pageOffset(filename))] = filepath
+		}
+	}
+	return pages, nil
+}
+
+/*
+	Reports of GoStatic in the root of the project (crawl report, script analysis), they are no part of the site and never prepared or uploaded
+*/
+var reportFiles = []string{"crawl-report.json", "crawl-report.txt", "script-assets.json"}
+
+/*
+	All files of the project in root without the reports
+*/
+func projectFiles(root string) ([]string, error) {
+	files, err := allFilesInDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var projectFiles []string
+	for _, filepath := range files {
+		if !isReport(root, filepath) {
+			projectFiles = append(projectFiles, filepath)
+		}
+	}
+	return projectFiles, nil
+}
+
+func isReport(root, filepath string) bool {
+	for _, report := range reportFiles {
+		if filepath == root+"/"+report {
+			return true
+		}
+	}
+	return false
 }
 
 // Creates list of all files in a directory, and its respective subdirectories.
@@ -105,40 +234,63 @@ func allFilesInDir(path string) ([]string, error) {
 }
 
 /*
-* Function finds all links to media files and returns them sanitized as a string slice
+* Function finds all links to media files of the file at filepath and returns them as they are written
+* The references are found by the table of the references package (i.e. src, data-src, poster, favicons, og:image), a reference is a media file
+* if it resolves to a file in root that is no page. Links are included, i.e. <a href="./assets/doc.pdf"> without download is a file of the project as well
  */
-func findAllFileLinks(newContent string) []string {
-	var fileLinks []string
-	srcRegEx := regexp.MustCompile(`src=("|')(.*?)("|')`) // Regex to find all src attributes
-	srcLinks := srcRegEx.FindAllString(newContent, -1)    // Find all src attributes
-
-	for _, link := range srcLinks {
-		link = srcRegEx.ReplaceAllString(link, `${2}`) // Replace src attribute with just the path
-		if strings.Contains(link, "assets") {          // If link is not a css, js, json or html file, append it to fileLinks)
-			fileLinks = append(fileLinks, link) // Append all links to fileLinks
-		}
+func findAllFileLinks(newContent, filepath, root string) []string {
+	var links []string
+	for _, reference := range refs.Extract(newContent) {
+		links = append(links, reference.Raw)
 	}
-	// Fix for featured images
-	urlRegEx := regexp.MustCompile(`url\((.*?)\)`)     // Regex to find all url attributes
-	urlLinks := urlRegEx.FindAllString(newContent, -1) // Find all url attributes
-	for _, link := range urlLinks {
-		link = urlRegEx.ReplaceAllString(link, `${1}`) // Replace url attribute with just the path
-		if strings.Contains(link, "assets") {          // If link is not a css, js, json or html file, append it to fileLinks)
+	links = append(links, refs.ExtractCSS(newContent)...) // Style elements of the page, i.e. featured images
+
+	var fileLinks []string
+	dir := linkDir(filepath, root)
+	for _, link := range removeDuplicateStr(links) {
+		if isMediaFile(link, dir, root) {
 			fileLinks = append(fileLinks, link)
 		}
 	}
+	return fileLinks
+}
 
-	hrefRegEx := regexp.MustCompile(`href=("|')(.*?)("|')`) // Regex to find all href attributes
-	hrefLinks := hrefRegEx.FindAllString(newContent, -1)    // Find all href attributes
-	for _, link := range hrefLinks {
-		link = hrefRegEx.ReplaceAllString(link, `${2}`) // Replace href attribute with just the path
-		if strings.Contains(link, "assets") {           // If link is not a css, js, json or html file, append it to fileLinks)
-			fileLinks = append(fileLinks, link)
-		}
+/*
+* Checks if the link from a file in dir (relative to root) leads to a file of the project that is no page
+ */
+func isMediaFile(link, dir, root string) bool {
+	if refs.IsLocal(link) || strings.Contains(link, "://") || strings.HasPrefix(link, "/") { // Other servers, and absolute paths on the iGEM Servers
+		return false
+	}
+	file := path.Join(root, dir, linkFile(link))
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return false
 	}
+	return !isPage(file)
+}
 
-	fileLinks = removeDuplicateStr(fileLinks)
-	return fileLinks // Return new array
+/*
+* Name of the file a link points to, without query and anchor, unescaped (i.e. &amp; and %20)
+ */
+func linkFile(link string) string {
+	link = html.UnescapeString(link)
+	if i := strings.IndexAny(link, "?#"); i >= 0 { // Cache busters and the anchors of fonts, i.e. font.eot?#iefix
+		link = link[:i]
+	}
+	if unescaped, err := neturl.PathUnescape(link); err == nil {
+		link = unescaped
+	}
+	return link
+}
+
+/*
+* Directory the links of the file are relative to, relative to root (i.e. /css). Templates are part of the pages calling them
+ */
+func linkDir(filepath, root string) string {
+	if isTemplate(root, filepath) {
+		return "/"
+	}
+	return path.Dir(strings.TrimPrefix(filepath, root))
 }
 
 /*
@@ -220,11 +372,11 @@ func replaceAllFileLinks(newContent string, fileLinks map[string]string) string
 }
 
 /*
-	Replaces DOCTYPE with the iGEM Standardtemplate of the team
+	Replaces DOCTYPE with the template of the team, the iGEM Standardtemplate is named like the team
 */
-func replaceDoctypeWithTemplate(newContent, teamname string) string {
-	newContent = strings.Replace(newContent, "<!DOCTYPE html>", "{{"+teamname+"}}", 1)
-	newContent = strings.Replace(newContent, "<!doctype html>", "{{"+teamname+"}}", 1)
+func replaceDoctypeWithTemplate(newContent, template string) string {
+	newContent = strings.Replace(newContent, "<!DOCTYPE html>", "{{"+template+"}}", 1)
+	newContent = strings.Replace(newContent, "<!doctype html>", "{{"+template+"}}", 1)
 	return newContent
 }
 
@@ -242,7 +394,7 @@ func replacePageExtensions(newContent, mathjax_url string) string {
 	mincssRegex := regexp.MustCompile(`((href|src)=("|').*?)(\.min\.css)("|')`)      // Regex to find all relative referenced css files
 	jsRegex := regexp.MustCompile(`((src|href)=("|').*)(\.js)(\?.*?)?("|')`)         // Regex to find all relative referenced js files
 	minjsRegex := regexp.MustCompile(`((src|href)=("|').*)(\.min\.js)(\?.*?)?("|')`) // Regex to find all relative referenced minjs files
-	indexRegEx := regexp.MustCompile(`index\.html`)                                  // Regex to find all href and src attributes that reference index.html
+	indexRegEx := regexp.MustCompile(`([/"'])index\.html`)                          // Regex to find all href and src attributes that reference index.html, not reindex.html
 	htmlRegEx := regexp.MustCompile(`\.html`)
 	mathJaxRegEx := regexp.MustCompile(`<!--.*?ADD_MATHJAX.*?-->`)
 	pageLoadRegEx := regexp.MustCompile(`<!--.*?ADD_PAGE_LOADING.*?-->`)
@@ -252,6 +404,7 @@ func replacePageExtensions(newContent, mathjax_url string) string {
 	jsReplace := `${1}?action=raw&ctype=text/javascript${3}`
 	minjsReplace := `${1}-min?action=raw&ctype=text/javascript${3}`
 	htmlReplace := ``
+	indexReplace := `${1}`
 	mathJaxReplace := `<script src="` + mathjax_url + `"></script>`
 	pageLoadReplace := `<script>document.addEventListener("DOMContentLoaded",function(){onscroll()}),window.addEventListener("load",function(){onscroll()});</script>`
 
@@ -259,7 +412,7 @@ func replacePageExtensions(newContent, mathjax_url string) string {
 	newContent = cssRegex.ReplaceAllString(newContent, cssReplace)         // Replace all '.css' in relative paths with ?action=raw&ctype=text/css, requesting the raw file from the server with the right content type
 	newContent = minjsRegex.ReplaceAllString(newContent, minjsReplace)     // Replace all '.min.js' in relative paths with ?action=raw&ctype=text/javascript, requesting the raw file from the server with the right content type
 	newContent = jsRegex.ReplaceAllString(newContent, jsReplace)           // Replace all '.js' in relative paths with ?action=raw&ctype=text/javascript, requesting the raw file from the server with the right content type
-	newContent = indexRegEx.ReplaceAllString(newContent, htmlReplace)      // Replace all href and src attributes that reference index.html with empty string
+	newContent = indexRegEx.ReplaceAllString(newContent, indexReplace)     // Replace all href and src attributes that reference index.html with empty string
 	newContent = htmlRegEx.ReplaceAllString(newContent, htmlReplace)       // Replace all '.html' in relative paths with empty string, so the raw file is requested from the server without the .html extension
 	newContent = mathJaxRegEx.ReplaceAllString(newContent, mathJaxReplace) // Replace the mathjax placeholder with the mathjax url form the config
 	newContent = pageLoadRegEx.ReplaceAllString(newContent, pageLoadReplace) // Replace the page loading placeholder with the page loading script -> Preventing wrong scrolling positions when loading images
@@ -267,12 +420,115 @@ func replacePageExtensions(newContent, mathjax_url string) string {
 	return newContent
 }
 
+/*
+* Prepares a stylesheet: files in url() (i.e. backgrounds and the sources of @font-face) are uploaded like the media files of the pages
+* and replaced with their url on the iGEM Servers. Their links are relative to the stylesheet, not to root.
+* @import of a local stylesheet is replaced with its page, requested raw with the css content type.
+ */
+func prepareStylesheet(newContent, filepath, root string, client *h.Handler, upload bool) (string, string) {
+	var links []string
+	for _, link := range refs.ExtractCSS(newContent) {
+		if isMediaFile(link, linkDir(filepath, root), root) {
+			links = append(links, link)
+		}
+	}
+
+	urls, errors := uploadRelativeFiles(links, filepath, root, client, upload)
+	if errors != "" {
+		return newContent, errors
+	}
+
+	newContent = refs.ReplaceCSS(newContent, func(link string) string {
+		return urls[link] // Empty if not uploaded, or not a file of the assets folder
+	})
+
+	newContent = cssImportRegEx.ReplaceAllStringFunc(newContent, func(statement string) string {
+		link := cssImportRegEx.FindStringSubmatch(statement)[1]
+		if strings.Contains(link, "://") || strings.HasPrefix(link, "//") || strings.HasPrefix(link, "data:") || !strings.Contains(link, ".css") {
+			return statement // Stylesheets of other servers are blocked by iGEM anyway
+		}
+		page := link[:strings.Index(link, ".css")]
+		if strings.HasSuffix(page, ".min") {
+			page = strings.TrimSuffix(page, ".min") + "-min"
+		}
+		return `@import url("` + page + `?action=raw&ctype=text/css")`
+	})
+
+	return newContent, ""
+}
+
+/*
+* Prepares a script: string literals linking to the assets folder (written by the script analysis of GoStatic) are uploaded
+* and replaced with the url of the file on the iGEM Servers, the relative links would be resolved against the page the script runs on.
+ */
+func prepareScript(newContent, filepath, root string, client *h.Handler, upload bool) (string, string) {
+	var links []string
+	for _, match := range scriptAssetRegEx.FindAllStringSubmatch(newContent, -1) {
+		links = append(links, match[2])
+	}
+
+	urls, errors := uploadRelativeFiles(links, filepath, root, client, upload)
+	if errors != "" {
+		return newContent, errors
+	}
+
+	newContent = scriptAssetRegEx.ReplaceAllStringFunc(newContent, func(literal string) string {
+		match := scriptAssetRegEx.FindStringSubmatch(literal)
+		if url := urls[match[2]]; url != "" {
+			return match[1] + url + match[3]
+		}
+		return literal
+	})
+	return newContent, ""
+}
+
+/*
+* Uploads the files of links relative to the file at filepath (i.e. ./../assets/bg.jpg in ./css/style.css) with fileUpload.
+* Returns the links mapped to the urls of the uploaded files. Queries are dropped, anchors are kept, svg fonts are selected by them (i.e. font.svg#icons).
+ */
+func uploadRelativeFiles(links []string, filepath, root string, client *h.Handler, upload bool) (map[string]string, string) {
+	dir := linkDir(filepath, root) // i.e. /css
+
+	rootLinks := make(map[string]string) // Link -> link relative to root, as fileUpload expects it
+	var fileLinks []string
+	for _, link := range links {
+		file := linkFile(link)
+		if strings.HasSuffix(file, ".css") { // Stylesheets are pages
+			continue
+		}
+		rootLinks[link] = "." + path.Join(dir, file)
+		fileLinks = append(fileLinks, rootLinks[link])
+	}
+
+	fileAssociations, errors := fileUpload(removeDuplicateStr(fileLinks), root, client, upload)
+	if errors != "" {
+		return nil, errors
+	}
+
+	urls := make(map[string]string)
+	for link, rootLink := range rootLinks {
+		url := fileAssociations[rootLink]
+		if url == "" {
+			continue
+		}
+		if i := strings.Index(link, "#"); i >= 0 {
+			url += link[i:]
+		}
+		urls[link] = url
+	}
+	return urls, ""
+}
+
+var scriptAssetRegEx = regexp.MustCompile(`(["'])((?:\./)?\.\./assets/[^"'\s]+)(["'])`)
+
+var cssImportRegEx = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"'()\s;]+)["']?\s*\)?`)
+
 /*
 * Uploads all files specified in the fileLinks map to the iGEM Wiki.
 * Uses the iGEM Wiki API to upload the files through the defined handler.
 * Returns a map of the uploaded files with the original file path as key and the new url as value.
  */
-func fileUpload(fileLinks []string, root string, client *h.Handler) (map[string]string, string) {
+func fileUpload(fileLinks []string, root string, client *h.Handler, upload bool) (map[string]string, string) {
 	result := make(map[string]string)
 	local_blacklist := make(map[string]bool)
 
@@ -284,34 +540,48 @@ func fileUpload(fileLinks []string, root string, client *h.Handler) (map[string]
 
 		res_url := ""
 		// fmt.Println(blacklist)
-		if blacklist[path] != "" && !local_blacklist[path] {
-			local_blacklist[path] = true
+		if blacklist[path] != "" {
 			result[link] = blacklist[path]
 			continue
 		}
+		if local_blacklist[path] { // Already tried in this run without getting an url
+			continue
+		}
+		local_blacklist[path] = true
 
-		if !local_blacklist[path] {
-			println("Uploading " + path)
-			url, err := client.UploadFile(path, false)
-			if err != nil {
-				if err.Error() == "alreadyUploadedInThisSession" || err.Error() == "fileAlreadyUploaded" {
-					local_blacklist[path] = true
-					res_url = client.GetFileUrl(url)
-					blacklist[path] = res_url
-					result[link] = res_url
-					continue
-					// return nil, err
-				} else {
-					err := "Error " + err.Error() + " uploading file: " + path + "\n"
-					println(err)
-					errors += err
-					continue
-				}
+		if !upload { // Only look up files that are already on the iGEM Servers
+			if client == nil { // Offline, the links keep pointing to the local files
+				continue
 			}
-			res_url = client.GetFileUrl(url)
+			if res_url, err := client.GetFileUrl(client.FileURL(path)); err == nil {
+				blacklist[path] = res_url
+				result[link] = res_url
+			}
+			continue
+		}
 
+		println("Uploading " + path)
+		url, err := client.UploadFile(path, false)
+		alreadyUploaded := err != nil && (err.Error() == "alreadyUploadedInThisSession" || err.Error() == "fileAlreadyUploaded")
+		if err != nil && !alreadyUploaded {
+			err := "Error " + err.Error() + " uploading file: " + path
+			out.Emit(out.Event{Type: out.Error, Category: "fileUpload", Message: err, Source: path})
+			errors += err + "\n"
+			continue
+		}
+		if url == "" { // No redirect to the file, i.e. the file has already been uploaded
+			url = client.FileURL(path)
+		}
+		res_url, err = client.GetFileUrl(url)
+		if err != nil { // Never write links without an url
+			err := "Error " + err.Error() + " looking up the url of file: " + path
+			out.Emit(out.Event{Type: out.Error, Category: "fileUpload", Message: err, Source: path})
+			errors += err + "\n"
+			continue
+		}
+		if !alreadyUploaded {
+			out.Emit(out.Event{Type: out.FileUploaded, Message: "Uploaded file: " + res_url, Source: path, URL: res_url})
 		}
-		println("Uploaded file: " + res_url)
 		result[link] = res_url
 		blacklist[path] = res_url
 	}
@@ -321,25 +591,12 @@ func fileUpload(fileLinks []string, root string, client *h.Handler) (map[string]
 
 /*
 * Upload all "non files" to the iGEM Wiki.
-* Uses the iGEM Wiki API to upload the files through the defined handler.
+* Uploads the files through the defined handler, to the wiki of the profile.
  */
 func pageUpload(filepath string, client *h.Handler) error {
-	offset := ""
 	filename := filepath[strings.LastIndex(filepath, "/")+1:]
 	if isPage(filename) {
-		if offset != "" {
-			if strings.Contains(filename, ".css") {
-				offset = "/css"
-			} else if strings.Contains(filename, ".js") {
-				offset = "/js"
-			}
-		} else {
-			if strings.Contains(filename, ".css") {
-				offset = "css"
-			} else if strings.Contains(filename, ".js") {
-				offset = "js"
-			}
-		}
+		offset := pageOffset(filename)
 
 		filepath = strings.ReplaceAll(filepath, "/", `\`)
 		url, err := client.Upload(filepath, offset, false)
@@ -349,17 +606,30 @@ func pageUpload(filepath string, client *h.Handler) error {
 			}
 			return err
 		}
-		println("Uploaded page: " + url)
+		out.Emit(out.Event{Type: out.PageUploaded, Message: "Uploaded page: " + url, Source: filepath, URL: url})
 	}
 	return nil
 
 }
 
+/*
+* Stylesheets and scripts are uploaded to their own subpages
+ */
+func pageOffset(filename string) string {
+	if strings.Contains(filename, ".css") {
+		return "css"
+	} else if strings.Contains(filename, ".js") {
+		return "js"
+	}
+	return ""
+}
+
 /*
 * Checks if the "OS.file" is a page.
  */
 func isPage(filepath string) bool {
-	if strings.Contains(filepath, ".html") || strings.Contains(filepath, ".htm") || strings.Contains(filepath, ".css") || strings.Contains(filepath, ".js") {
+	switch path.Ext(filepath) { // Not only contained in the name, JSON files would be pages as well
+	case ".html", ".htm", ".css", ".js":
 		return true
 	}
 	return false
@@ -0,0 +1,65 @@
+package GoGEMfilehandling
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFindAllFileLinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"index.html", "about.html", "assets/doc.pdf", "assets/logo.png", "assets/bg.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"image", `<img src="./assets/logo.png">`, []string{"./assets/logo.png"}},
+		{"link to a file", `<a href="./assets/doc.pdf">Report</a>`, []string{"./assets/doc.pdf"}},
+		{"download link", `<a href="./assets/doc.pdf" download>Report</a>`, []string{"./assets/doc.pdf"}},
+		{"link to a page", `<a href="./about.html">About</a>`, nil},
+		{"link to another server", `<a href="https://example.org/doc.pdf">Report</a>`, nil},
+		{"missing file", `<a href="./assets/missing.pdf">Report</a>`, nil},
+		{"style element", `<style>body { background: url(./assets/bg.jpg) }</style>`, []string{"./assets/bg.jpg"}},
+	}
+	for _, test := range tests {
+		got := findAllFileLinks(test.content, root+"/index.html", root)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: findAllFileLinks = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestProjectFilesWithoutReports(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"index.html", "crawl-report.json", "crawl-report.txt", "script-assets.json", "assets/crawl-report.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := projectFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	want := []string{root + "/assets/crawl-report.txt", root + "/index.html"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("projectFiles = %v, want %v", files, want)
+	}
+}
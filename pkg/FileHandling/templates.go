@@ -0,0 +1,150 @@
+package GoGEMfilehandling
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	out "github.com/Jackd4w/GoGEM/pkg/Output"
+)
+
+/*
+	Templates of the team used when preparing the pages.
+	The files in the templates folder of the project are uploaded as Template:[Team]/[name], a file named like the team is the team template itself.
+*/
+type Templates struct {
+	Team string                       // Teamname, the templates are called as {{Team/name}}
+	Page func(filename string) string // Name of the team template replacing the DOCTYPE of the page, i.e. Profile.Template
+}
+
+const templateDir = "templates"
+
+/*
+	Name of the team template replacing the DOCTYPE of the page (file), the standard template of iGEM is named like the team
+*/
+func (t Templates) PageTemplate(filename string) string {
+	if t.Page == nil {
+		return t.Team
+	}
+	return t.Page(filename)
+}
+
+/*
+	Returns all files in the templates folder of root, mapped from their page (i.e. Template:TU_Darmstadt/header) to the local file
+*/
+func TemplatePaths(root, teamname string) (map[string]string, error) {
+	templates := make(map[string]string)
+	files, err := allFilesInDir(root + "/" + templateDir)
+	if os.IsNotExist(err) { // Projects without templates
+		return templates, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.Contains(filepath.Base(file), ".htm") {
+			templates[templateTitle(file, teamname)] = file
+		}
+	}
+	return templates, nil
+}
+
+/*
+	Uploads all files in the templates folder of root, the files have to be prepared with PrepareFiles beforehand.
+	Templates that did not change are skipped.
+*/
+func UploadTemplates(root string, client *h.Handler) error {
+	templates, err := TemplatePaths(root, client.Teamname())
+	if err != nil {
+		return err
+	}
+	titles := make([]string, 0, len(templates))
+	for title := range templates {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	for _, title := range titles {
+		content, err := ioutil.ReadFile(templates[title])
+		if err != nil {
+			return err
+		}
+		url, err := client.EditPage(title, string(content))
+		if err != nil {
+			if err.Error() == "fileAlreadyUploaded" {
+				continue
+			}
+			out.Emit(out.Event{Type: out.Error, Category: "templateUpload", Message: "Error " + err.Error() + " uploading template: " + templates[title], Source: templates[title]})
+			return err
+		}
+		out.Emit(out.Event{Type: out.PageUploaded, Message: "Uploaded template: " + url, Source: templates[title], URL: url})
+	}
+	return nil
+}
+
+/*
+	Page of a template file: templates/header.html becomes Template:[Team]/header, templates/[Team].html the team template Template:[Team]
+*/
+func templateTitle(file, teamname string) string {
+	name := strings.Split(filepath.Base(file), ".")[0]
+	if name == teamname {
+		return "Template:" + teamname
+	}
+	return "Template:" + teamname + "/" + name
+}
+
+/*
+	Collects the content of every template of root, mapped to its call (i.e. {{TU_Darmstadt/header}}).
+	Read before the templates are prepared, so they can be found in the unprepared pages.
+*/
+func templateCalls(root, teamname string) (map[string]string, error) {
+	calls := make(map[string]string)
+	if teamname == "" {
+		return calls, nil
+	}
+	templates, err := TemplatePaths(root, teamname)
+	if err != nil {
+		return nil, err
+	}
+	for title, file := range templates {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		trimmed := strings.TrimSpace(string(content))
+		if strings.HasPrefix(trimmed, "<") { // Only markup, short text would be replaced everywhere
+			calls[trimmed] = "{{" + strings.TrimPrefix(title, "Template:") + "}}"
+		}
+	}
+	return calls, nil
+}
+
+/*
+	Replaces every part of the page that is identical to a template with the call of the template, i.e. the header all WordPress pages share.
+	Longer templates are replaced first.
+*/
+func replaceWithTemplateCalls(newContent string, calls map[string]string) string {
+	contents := orderByLength(calls)
+	for _, content := range contents {
+		newContent = strings.ReplaceAll(newContent, content, calls[content])
+	}
+	return newContent
+}
+
+func orderByLength(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+	return keys
+}
+
+func isTemplate(root, path string) bool {
+	return strings.HasPrefix(path, root+"/"+templateDir+"/")
+}
@@ -0,0 +1,117 @@
+package GoGEMfilehandling
+
+import (
+	"io/ioutil"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+	refs "github.com/Jackd4w/GoGEM/pkg/References"
+)
+
+/*
+	Problem found in a prepared file, Kind is a short machine readable identifier:
+	dangling: the reference leads to a page of the team that will not be uploaded
+	wordpress: absolute url to the WordPress Page that has not been rewritten
+	asset: link to the assets folder that has not been replaced with an uploaded file
+*/
+type Problem struct {
+	File      string `json:"file"`
+	Kind      string `json:"kind"`
+	Reference string `json:"reference"`
+	Message   string `json:"message"`
+}
+
+/*
+	Checks the files in root prepared by PrepareFiles before their pages are uploaded.
+	Every reference of the references table and every url() is resolved against the page the file will be uploaded to, and checked against the pages that will be uploaded.
+	wordpress is the url of the WordPress Page, it can be empty.
+	Nothing is requested from the iGEM Servers, the handler is only needed for the naming of the pages.
+	The problems are sorted by file.
+*/
+func Validate(root, wordpress string, client *h.Handler) ([]Problem, error) {
+	var problems []Problem
+
+	pages, err := PagePaths(root, client)
+	if err != nil {
+		return nil, err
+	}
+	wpHost := ""
+	if u, err := neturl.Parse(wordpress); err == nil {
+		wpHost = u.Host
+	}
+
+	for pageurl, file := range pages {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		// Relative references are resolved by the browser against the url of the page. Index pages are reached with a trailing slash, see CreateUppercaseRedirects
+		base, err := neturl.Parse(client.BaseURL() + pageurl)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Split(file[strings.LastIndex(file, "/")+1:], ".")[0] == "index" {
+			base.Path += "/"
+		}
+
+		var references []string
+		if !strings.Contains(file, ".css") { // Stylesheets only reference files through url()
+			for _, reference := range refs.Extract(string(content)) {
+				references = append(references, reference.URL)
+			}
+		}
+		references = append(references, refs.ExtractCSS(string(content))...)
+
+		for _, reference := range removeDuplicateStr(references) {
+			if problem := checkReference(reference, base, wpHost, client.Teamname(), pages); problem != nil {
+				problem.File = file
+				problems = append(problems, *problem)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].File < problems[j].File
+	})
+	return problems, nil
+}
+
+func checkReference(reference string, base *neturl.URL, wpHost, teamname string, pages map[string]string) *Problem {
+	reference = strings.TrimSpace(reference)
+	if reference == "" || strings.HasPrefix(reference, "#") || strings.HasPrefix(reference, "mailto:") || strings.HasPrefix(reference, "tel:") || strings.HasPrefix(reference, "javascript:") || strings.HasPrefix(reference, "data:") || strings.Contains(reference, "{{") {
+		return nil
+	}
+	ref, err := neturl.Parse(reference)
+	if err != nil {
+		return nil
+	}
+
+	if wpHost != "" && ref.Host == wpHost {
+		return &Problem{Kind: "wordpress", Reference: reference, Message: "Absolute link to the WordPress Page: " + reference}
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Host != base.Host {
+		return nil // Other wikis and external pages are not part of the upload
+	}
+
+	if strings.Contains(resolved.Path, "/assets/") {
+		return &Problem{Kind: "asset", Reference: reference, Message: "Link to a file that has not been uploaded: " + reference}
+	}
+
+	teamPath := "/Team:" + teamname
+	if resolved.Path != teamPath && !strings.HasPrefix(resolved.Path, teamPath+"/") {
+		return nil // Not a page of the team, i.e. /wiki/images/
+	}
+	pageurl := strings.TrimSuffix(resolved.Path, "/")
+	_, ok := pages[pageurl]
+	if !ok {
+		_, ok = pages[pageurl+"/"] // Roots of the team and the offset
+	}
+	if !ok {
+		return &Problem{Kind: "dangling", Reference: reference, Message: "Link to " + pageurl + ", which will not be uploaded: " + reference}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package GoGEMgostatic
+
+import (
+	neturl "net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+	Canonical form of the urls of the crawl, so every file is crawled, named and replaced only once.
+	WordPress links the same file in different spellings: with tracking parameters, cache busters (?ver=5.8), the default port or upper case hosts.
+*/
+
+// Query parameters that do not change the content, names ending with * are prefixes
+var trackingParams = []string{"utm_*", "ver", "fbclid", "gclid", "msclkid", "_ga", "mc_cid", "mc_eid"}
+
+var (
+	unsafeNameRegEx = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	indexNameRegEx  = regexp.MustCompile(`(?i)index`)
+)
+
+/*
+	Returns the canonical form of an absolute url: lower case scheme and host, no default port, "/" for an empty path,
+	no fragment and no tracking parameters, the remaining parameters sorted by name. Empty for urls that are no http(s) urls.
+	Trailing slashes of paths are kept, the server decides if /about and /about/ are the same page (see responseStore.redirects).
+*/
+func canonicalURL(link string) string {
+	u, err := neturl.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for name := range query {
+		if isTrackingParam(name) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode() // Sorted by name
+	u.ForceQuery = false
+	return u.String()
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range trackingParams {
+		if name == param || (strings.HasSuffix(param, "*") && strings.HasPrefix(name, strings.TrimSuffix(param, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Appends the query of a canonical url to the name of its file, so pages like ?page_id=42 get a stable file of their own (i.e. page_id-42 for the root of the site, whose name is empty).
+	The part taken from the query never contains "index": the upload maps pages named index to the root of the team.
+*/
+func queryName(name, query string) string {
+	if query == "" {
+		return name
+	}
+	slug := strings.Trim(unsafeNameRegEx.ReplaceAllString(query, "-"), "-")
+	slug = indexNameRegEx.ReplaceAllString(slug, "idx")
+	if slug == "" {
+		slug = "q"
+	}
+	if name == "" {
+		return slug
+	}
+	return name + "-" + slug
+}
+
+/*
+	Returns file, or file with a number before the extension if it is used by another url already
+*/
+func uniqueName(file string, used map[string]bool) string {
+	name := file
+	ext := path.Ext(file)
+	for i := 2; used[name]; i++ {
+		name = strings.TrimSuffix(file, ext) + "-" + strconv.Itoa(i) + ext
+	}
+	used[name] = true
+	return name
+}
@@ -0,0 +1,159 @@
+package GoGEMgostatic
+
+import (
+	"testing"
+
+	h "github.com/Jackd4w/GoGEM/pkg/Handler"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"default http port", "http://example.org:80/about/", "http://example.org/about/"},
+		{"default https port", "https://example.org:443/about/", "https://example.org/about/"},
+		{"other port", "http://localhost:8080/wp/", "http://localhost:8080/wp/"},
+		{"https on port 80", "https://example.org:80/", "https://example.org:80/"},
+		{"ipv6 host", "http://[::1]:8443/wp/", "http://[::1]:8443/wp/"},
+		{"ipv6 default port", "http://[::1]:80/", "http://[::1]/"},
+		{"host and scheme case", "HTTPS://Example.ORG/About/", "https://example.org/About/"},
+		{"fragment", "http://example.org/about/#team", "http://example.org/about/"},
+		{"sorted query", "http://example.org/?page_id=42&lang=de", "http://example.org/?lang=de&page_id=42"},
+		{"tracking parameters", "http://example.org/style.css?ver=5.8&utm_source=x&utm_medium=y&fbclid=z", "http://example.org/style.css"},
+		{"tracking and content parameters", "http://example.org/?utm_campaign=a&p=7", "http://example.org/?p=7"},
+		{"empty query", "http://example.org/?", "http://example.org/"},
+		{"bare root", "http://example.org", "http://example.org/"},
+		{"trailing slash kept", "http://example.org/about", "http://example.org/about"},
+		{"no http", "mailto:team@example.org", ""},
+		{"relative", "/about/", ""},
+	}
+	for _, test := range tests {
+		if got := canonicalURL(test.link); got != test.want {
+			t.Errorf("%s: canonicalURL(%q) = %q, want %q", test.name, test.link, got, test.want)
+		}
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"about", "", "about"},
+		{"", "page_id=42", "page_id-42"},
+		{"", "p=7", "p-7"},
+		{"shop", "product=red+shirt", "shop-product-red-shirt"},
+		{"", "view=index", "view-idx"},
+		{"", "Index=1", "idx-1"},
+		{"", "=", "q"},
+	}
+	for _, test := range tests {
+		if got := queryName(test.name, test.query); got != test.want {
+			t.Errorf("queryName(%q, %q) = %q, want %q", test.name, test.query, got, test.want)
+		}
+	}
+}
+
+func TestCreateFileLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		site  string
+		pages map[string]string // Url -> content type
+		want  map[string]string // Url -> file
+	}{
+		{
+			name: "root and pages",
+			site: "http://example.org/",
+			pages: map[string]string{
+				"http://example.org/":       "text/html",
+				"http://example.org/about/": "text/html",
+				"http://example.org/about":  "text/html",
+			},
+			want: map[string]string{
+				"http://example.org/":       "./index.html",
+				"http://example.org/about":  "./about.html",
+				"http://example.org/about/": "./about-2.html",
+			},
+		},
+		{
+			name: "query pages do not collide with the index page",
+			site: "http://example.org/",
+			pages: map[string]string{
+				"http://example.org/":                     "text/html; charset=UTF-8",
+				"http://example.org/?page_id=42":          "text/html; charset=UTF-8",
+				"http://example.org/index.php?p=7":        "text/html; charset=UTF-8",
+				"http://example.org/?view=index":          "text/html; charset=UTF-8",
+				"http://example.org/shop/?product=shirt":  "text/html; charset=UTF-8",
+				"http://example.org/blog/index.php":       "text/html; charset=UTF-8",
+				"http://example.org/reindex/":             "text/html; charset=UTF-8",
+				"http://example.org/style.css?media=1":    "text/css",
+				"http://example.org/wp-content/a.png?x=1": "image/png",
+			},
+			want: map[string]string{
+				"http://example.org/":                     "./index.html",
+				"http://example.org/?page_id=42":          "./page_id-42.html",
+				"http://example.org/index.php?p=7":        "./p-7.html",
+				"http://example.org/?view=index":          "./view-idx.html",
+				"http://example.org/shop/?product=shirt":  "./shop-product-shirt.html",
+				"http://example.org/blog/index.php":       "./blog.html",
+				"http://example.org/reindex/":             "./reindex.html",
+				"http://example.org/style.css?media=1":    "./css/style-media-1.css",
+				"http://example.org/wp-content/a.png?x=1": "./assets/a-x-1.png",
+			},
+		},
+		{
+			name: "subpath install on a port",
+			site: "http://localhost:8080/wp/",
+			pages: map[string]string{
+				"http://localhost:8080/wp/":       "text/html",
+				"http://localhost:8080/wp/about/": "text/html",
+				"http://localhost:8080/":          "text/html",
+			},
+			want: map[string]string{
+				"http://localhost:8080/wp/":       "./index.html",
+				"http://localhost:8080/wp/about/": "./about.html",
+				"http://localhost:8080/":          "./root.html",
+			},
+		},
+		{
+			name: "ipv6 host",
+			site: "http://[::1]:8443/",
+			pages: map[string]string{
+				"http://[::1]:8443/":            "text/html",
+				"http://[::1]:8443/js/main.js":  "application/javascript",
+				"http://[::1]:8443/js/a.min.js": "application/javascript",
+			},
+			want: map[string]string{
+				"http://[::1]:8443/":            "./index.html",
+				"http://[::1]:8443/js/main.js":  "./js/main.js",
+				"http://[::1]:8443/js/a.min.js": "./js/a-min.js",
+			},
+		},
+	}
+	for _, test := range tests {
+		if err := createFileLinks(test.pages, test.site); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for link, want := range test.want {
+			if got := test.pages[link]; got != want {
+				t.Errorf("%s: file of %s = %q, want %q", test.name, link, got, want)
+			}
+		}
+
+		index := 0 // Only the page of the site may become the root of the team on the wiki
+		for link, file := range test.pages {
+			if test.want[link] != "./index.html" && h.TeamPageURL("T", "", file) == "/Team:T/" {
+				t.Errorf("%s: %s (%s) would be uploaded to the root of the team", test.name, link, file)
+			}
+			if file == "./index.html" {
+				index++
+			}
+		}
+		if index != 1 {
+			t.Errorf("%s: %d pages became index.html, want 1", test.name, index)
+		}
+	}
+}
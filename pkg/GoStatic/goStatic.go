@@ -1,50 +1,128 @@
 package GoGEMgostatic
 
 import (
+	"bytes"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	refs "github.com/Jackd4w/GoGEM/pkg/References"
 	"github.com/gocolly/colly"
 )
 
+/*
+	Options of the clone
+*/
+type Options struct {
+	Fonts        map[string]string // Fonts replaced in svg files, see the config
+	Insecure     bool              // Ignores HTTPS Certificate warnings
+	ScriptAssets bool              // Finds files referenced in string literals of the scripts, see analyseScripts
+	CacheDir     string            // Directory of the stored responses, one folder per domain. Defaults to GoGEM/crawl in the cache directory of the user
+	Offline      bool              // Rebuilds the project from the stored responses of an earlier crawl, without requesting anything
+	Parallelism  int               // Number of parallel requests, at least one
+	Delay        time.Duration     // Delay between two requests
+	RandomDelay  time.Duration     // Maximum random delay added to Delay
+	UserAgent    string            // Defaults to DefaultUserAgent
+	Robots       bool              // Respects the robots.txt of the site
+}
+
+const DefaultUserAgent = "GoGEM (+https://github.com/Jackd4w/GoGEM)"
+
 /*
 
 	Download all files from the given url and save them to the given path.
 	Tested with WordPress, should also work with other websites.
-	At the moment only links on html pages are regarded.
-	This can lead to problems if files are only included via a css file (aka fonts) or via js files
+	At the moment only links on html pages are regarded, and with ScriptAssets the string literals of the scripts.
+	This can lead to problems if files are only included via a css file (aka fonts)
 
 */
-func GoStatic(url, path string, fonts map[string]string, insecure bool) (string, error) {
-	url = sanitize_url(url)
+func GoStatic(url, path string, opts Options) (string, error) {
+	if _, err := urlToDomain(url); err != nil {
+		return "", err
+	}
+	url = canonicalURL(url)
+	insecure := opts.Insecure
 
 	if insecure {
 		println("Warning: Using insecure connection")
-		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
 	project_path, err := createProject(path, url)
 	if err != nil {
 		return "", err
 	}
-	pages, remove, err := crawlDomain(url, insecure)
+
+	domain, err := urlToDomain(url)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(opts.CacheDir, hostDir(domain))
+	if opts.CacheDir == "" {
+		if dir, err = storeDir(domain); err != nil {
+			return "", err
+		}
+	}
+	store, err := openStore(dir, insecure, opts.Offline)
+	if err != nil {
+		return "", err
+	}
+	defer store.close()
+
+	var pages, remove map[string]string
+	report := newCrawlReport()
+	if opts.Offline {
+		println("Offline, using the stored responses in " + dir)
+		pages, remove, err = crawlStore(url, store, report)
+	} else {
+		pages, remove, err = crawlDomain(url, store, opts, report)
+	}
 	if err != nil {
 		return "", err
 	}
 
+	var literals map[string]string
+	if opts.ScriptAssets {
+		println("Analysing scripts...")
+		var uncertain []UncertainLiteral
+		literals, uncertain, err = analyseScripts(pages, remove, url, store)
+		if err != nil {
+			return "", err
+		}
+		if err = reportScriptLiterals(project_path, uncertain); err != nil {
+			return "", err
+		}
+	}
+
 	err = createFileLinks(pages, url)
 	if err != nil {
 		return "", err
 	}
 
-	err = fetchPages(pages, remove, fonts, project_path)
+	err = report.write(project_path, pages, remove)
+	if err != nil {
+		return "", err
+	}
+
+	err = fetchPages(pages, remove, literals, opts.Fonts, project_path, store)
+	if err != nil {
+		return "", err
+	}
+
+	err = createTemplates(project_path)
 	if err != nil {
 		return "", err
 	}
@@ -55,15 +133,21 @@ func GoStatic(url, path string, fonts map[string]string, insecure bool) (string,
 /*
 
 	Creates uniform folder structure for the project.
+	The folder is named after the host, WordPress installed in a subpath gets it appended (i.e. localhost_8080_wp for http://localhost:8080/wp/).
 	After creation it's empty.
 
 */
 func createProject(path, url string) (string, error) {
 
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
 	domain, err := urlToDomain(url)
 	if err != nil {
 		return "", err
 	}
+	domain = strings.Join(append([]string{hostDir(domain)}, delete_empty(strings.Split(u.Path, "/"))...), "_")
 
 	if path == "" {
 		if path, err = os.Getwd(); err != nil { // Set path to current working directory
@@ -90,144 +174,388 @@ func createProject(path, url string) (string, error) {
 /*
 
 	Crawl the domain and create a map of all pages.
-	Using colly.
+	Using colly, asynchronous with the limits of opts (parallel requests, delays) to spare cheap hosting.
+	Every response is kept in the store, files that did not change since the last run are not downloaded again.
+	Media files are streamed into the store instead of being buffered by colly, pages, stylesheets and scripts stay below the body limit of colly.
+	Every visited url is recorded in the report.
 
 */
-func crawlDomain(url string, insecure bool) (pages, remove map[string]string, err error) { // Crawl domain
+func crawlDomain(url string, store *responseStore, opts Options, report *crawlReport) (pages, remove map[string]string, err error) { // Crawl domain
 	pages = make(map[string]string)  // Map of all found page links to file/type
 	remove = make(map[string]string) // Map of all links that need to be removed
+	var mutex sync.Mutex             // The callbacks run in parallel
+	progress := &crawlProgress{}
 
 	domain, err := urlToDomain(url)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	c := colly.NewCollector(
-		colly.AllowedDomains(domain),
-	)
+	c := NewCollector(domain, opts.Insecure)
+	c.ParseHTTPErrorResponse = true // Needed to see 304 Not Modified in OnResponse
+	c.Async = true
+	c.IgnoreRobotsTxt = !opts.Robots
+	c.UserAgent = opts.UserAgent
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if err := c.Limit(&colly.LimitRule{DomainGlob: "*", Parallelism: opts.Parallelism, Delay: opts.Delay, RandomDelay: opts.RandomDelay}); err != nil {
+		return nil, nil, err
+	}
+	store.userAgent = c.UserAgent
 
-	if insecure {
-		c.WithTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		})
+	parallelism := opts.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
 	}
+	downloads := make(chan bool, parallelism) // The media files are limited like the requests of the collector
 
-	c.OnHTML("a[href]", func(e *colly.HTMLElement) { // Register callback functions for all types of links
-		link := e.Attr("href")
-		if !strings.Contains(link, "impressum") && !strings.Contains(link, "wp-login") && !strings.Contains(link, "wp-admin") {
-			c.Visit(e.Request.AbsoluteURL(link))
+	streamMedia := func(link string) {
+		downloads <- true
+		entry, err := store.get(link) // Streams the body to disk
+		time.Sleep(opts.Delay + randomDelay(opts.RandomDelay))
+		<-downloads
+		if err != nil {
+			progress.add(&progress.failed)
+			report.visited(link, 0, "", 0, err)
+			println(err.Error() + " " + link)
+			return
 		}
-	})
+		if entry.Status < 200 || entry.Status >= 300 {
+			progress.add(&progress.failed)
+		} else {
+			progress.add(&progress.visited)
+		}
+		report.visited(link, entry.Status, entry.ContentType, store.size(entry), nil)
 
-	c.OnHTML("link[href]", func(e *colly.HTMLElement) {
-		link := e.Attr("href")
-		c.Visit(e.Request.AbsoluteURL(link))
-	})
+		mutex.Lock()
+		visitResponse(link, entry, store, pages, remove) // Media files have no references
+		mutex.Unlock()
+	}
 
-	c.OnHTML("script[src]", func(e *colly.HTMLElement) {
-		link := e.Attr("src")
-		c.Visit(e.Request.AbsoluteURL(link))
+	c.OnRequest(func(r *colly.Request) { // Called after colly checked the domain and robots.txt
+		progress.add(&progress.queued)
+		if mediaExtRegEx.MatchString(r.URL.Path) { // Videos would be buffered in memory by colly
+			r.Abort()
+			streamMedia(r.URL.String())
+			return
+		}
+		store.conditional(r.URL.String(), *r.Headers)
 	})
 
-	c.OnHTML("img[src]", func(e *colly.HTMLElement) {
-		link := e.Attr("src")
-		c.Visit(e.Request.AbsoluteURL(link))
+	c.OnError(func(r *colly.Response, err error) { // Connection errors and redirects out of the domain, the status codes are handled in OnResponse
+		progress.add(&progress.failed)
+		report.visited(canonicalURL(r.Request.URL.String()), r.StatusCode, "", 0, err)
+		println(err.Error() + " " + r.Request.URL.String())
 	})
 
-	c.OnHTML("video[src]", func(e *colly.HTMLElement) {
-		link := e.Attr("src")
-		c.Visit(e.Request.AbsoluteURL(link))
-	})
+	c.RedirectHandler = func(req *http.Request, via []*http.Request) error { // Same as the default of colly, but the redirects are stored for offline crawls
+		if len(via) >= 10 {
+			return http.ErrUseLastResponse
+		}
+		last := via[len(via)-1]
+		for name, values := range last.Header {
+			req.Header[name] = values
+		}
+		req.Header.Del("If-None-Match") // The validators belong to the last url
+		req.Header.Del("If-Modified-Since")
+		from, to := canonicalURL(last.URL.String()), canonicalURL(req.URL.String())
+		store.conditional(to, req.Header)
+		if from != to { // Redirects that only add tracking parameters
+			store.redirect(from, req.Response.StatusCode, to)
+			report.redirected(from, to)
+		}
+		return nil
+	}
 
-	c.OnHTML("audio[src]", func(e *colly.HTMLElement) {
-		link := e.Attr("src")
-		c.Visit(e.Request.AbsoluteURL(link))
-	})
+	c.OnResponse(func(r *colly.Response) { // When we get a response create pages list, and follow all references of the pages, see the table of the references package
+		page := canonicalURL(r.Request.URL.String())
+		var entry *storedResponse
+		var err error // The callbacks run in parallel
+		if len(r.Body) >= c.MaxBodySize && !isText(r.Headers.Get("Content-Type")) { // Media file without extension, cut off by colly
+			entry, err = store.get(page)
+		} else {
+			entry, err = store.save(page, r.StatusCode, *r.Headers, bytes.NewReader(r.Body))
+		}
+		if err != nil {
+			progress.add(&progress.failed)
+			report.visited(page, r.StatusCode, r.Headers.Get("Content-Type"), int64(len(r.Body)), err)
+			println(err.Error() + " " + page)
+			return
+		}
+		if entry.Status < 200 || entry.Status >= 300 {
+			progress.add(&progress.failed)
+			report.visited(page, entry.Status, entry.ContentType, int64(len(r.Body)), nil)
+		} else {
+			progress.add(&progress.visited)
+			report.visited(page, entry.Status, entry.ContentType, store.size(entry), nil) // The body is empty on 304
+		}
 
-	c.OnHTML("header[style]", func(e *colly.HTMLElement) { // Fix for featured images
-		link := e.Attr("style")
-		urlRegex := regexp.MustCompile(`.*url\((.*?)\).*;`)
-		replace := `${1}`
-		link = urlRegex.ReplaceAllString(link, replace)
-		c.Visit(e.Request.AbsoluteURL(link))
+		mutex.Lock()
+		links := visitResponse(page, entry, store, pages, remove)
+		mutex.Unlock()
+		for _, link := range links {
+			if link = canonicalURL(r.Request.AbsoluteURL(link)); link == "" {
+				continue
+			}
+			report.referenced(link, page)
+			if err := c.Visit(link); err == colly.ErrRobotsTxtBlocked {
+				report.visited(link, 0, "", 0, err)
+				println("Blocked by robots.txt " + link)
+			}
+		}
 	})
 
-	c.OnResponse(func(r *colly.Response) { // When we get a response create pages list
-		if r.StatusCode != 200 {
-			println(fmt.Sprint(r.StatusCode) + " " + r.Request.URL.String())
+	if err := c.Visit(url); err != nil { // Start Crawling from the given URL
+		return nil, nil, err
+	}
+	c.Wait()
+	progress.print()
+
+	return pages, remove, nil
+
+}
+
+var mediaExtRegEx = regexp.MustCompile(`(?i)\.(jpe?g|png|gif|svg|webp|avif|ico|bmp|tiff?|mp4|m4v|webm|ogv|ogg|mov|avi|mp3|wav|m4a|flac|woff2?|ttf|eot|otf|pdf|zip|gz|docx?|xlsx?|pptx?)$`)
+
+/*
+	Random part of the delay between two requests, like the RandomDelay of colly
+*/
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+/*
+	Counts the urls of the crawl, printed every progressInterval visited urls and at the end
+*/
+type crawlProgress struct {
+	mutex   sync.Mutex
+	queued  int // Requested, including the visited and failed ones
+	visited int
+	failed  int
+}
+
+const progressInterval = 25
+
+func (p *crawlProgress) add(counter *int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	*counter++
+	if counter != &p.queued && (p.visited+p.failed)%progressInterval == 0 {
+		p.printLocked()
+	}
+}
+
+func (p *crawlProgress) print() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.printLocked()
+}
+
+func (p *crawlProgress) printLocked() {
+	println(fmt.Sprintf("Crawled %d of %d urls, %d failed", p.visited+p.failed, p.queued, p.failed))
+}
+
+/*
+	Crawls the responses of an earlier run in the store like crawlDomain, without requesting anything.
+	References that were not stored are skipped, i.e. pages added to the site after the last crawl.
+*/
+func crawlStore(url string, store *responseStore, report *crawlReport) (pages, remove map[string]string, err error) {
+	pages = make(map[string]string)
+	remove = make(map[string]string)
+
+	domain, err := urlToDomain(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queue := []string{url}
+	visited := map[string]bool{url: true}
+	for len(queue) > 0 {
+		link := queue[0]
+		queue = queue[1:]
+
+		entry, err := store.get(link)
+		if err == errNotCached && link != url {
+			report.visited(link, 0, "", 0, err)
+			println("Not stored, skipping " + link)
+			continue
 		}
-		filetype := r.Headers.Get("Content-Type")
-		if filetype == "image/svg+xml" || (!strings.Contains(filetype, "json") && !strings.Contains(filetype, "xml")) { // Skipping JSON and XML files, as JSON is the response from the WP REST API, and XML the response of the legacy XML-RPC API
-			pages[r.Request.URL.String()] = filetype
-		} else {
-			remove[r.Request.URL.String()] = ""
+		if err != nil {
+			return nil, nil, errors.New(err.Error() + " " + link)
 		}
-	})
-	c.Visit(url) // Start Crawling from the given URL
+		hops := store.redirects(link)
+		for i := 1; i < len(hops); i++ {
+			report.redirected(hops[i-1], hops[i])
+		}
+		report.visited(entry.URL, entry.Status, entry.ContentType, store.size(entry), nil)
 
+		for _, reference := range visitResponse(entry.URL, entry, store, pages, remove) {
+			next := canonicalURL(absoluteURL(entry.URL, reference)) // Relative to the url after the redirects, like colly
+			if next == "" || !inDomain(next, domain) {
+				continue
+			}
+			report.referenced(next, entry.URL)
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			queue = append(queue, next)
+		}
+	}
 	return pages, remove, nil
+}
+
+/*
+	Sorts a response of the crawl into pages and remove, and returns the references of html pages and stylesheets that have to be crawled as well
+*/
+func visitResponse(link string, entry *storedResponse, store *responseStore, pages, remove map[string]string) []string {
+	if entry.Status != 200 {
+		println(fmt.Sprint(entry.Status) + " " + link)
+		if entry.Status < 200 || entry.Status >= 300 {
+			return nil
+		}
+	}
+	filetype := entry.ContentType
+	if filetype == "image/svg+xml" || (!strings.Contains(filetype, "json") && !strings.Contains(filetype, "xml")) { // Skipping JSON and XML files, as JSON is the response from the WP REST API, and XML the response of the legacy XML-RPC API
+		pages[link] = filetype
+	} else {
+		remove[link] = ""
+	}
+
+	if !strings.Contains(filetype, "text/html") && !strings.Contains(filetype, "text/css") {
+		return nil
+	}
+	body, err := store.read(entry) // The body of the response is empty on 304
+	if err != nil {
+		println(err.Error() + " " + link)
+		return nil
+	}
+	if strings.Contains(filetype, "text/css") { // url() and @import, i.e. fonts, backgrounds and imported stylesheets
+		return refs.ExtractCSS(string(body))
+	}
+	var links []string
+	for _, reference := range refs.Extract(string(body)) {
+		link := reference.URL
+		if reference.Element == "a" && (strings.Contains(link, "impressum") || strings.Contains(link, "wp-login") || strings.Contains(link, "wp-admin")) {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+/*
+	Resolves a reference of the page base like colly, anchors on the same page are empty
+*/
+func absoluteURL(base, link string) string {
+	if strings.HasPrefix(link, "#") {
+		return ""
+	}
+	u, err := neturl.Parse(base)
+	if err != nil {
+		return ""
+	}
+	abs, err := u.Parse(link)
+	if err != nil {
+		return ""
+	}
+	abs.Fragment = ""
+	return abs.String()
+}
+
+/*
+	Checks if the url is served by domain, like the allowed domains of the collector
+*/
+func inDomain(link, domain string) bool {
+	u, err := neturl.Parse(link)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host == domain
+}
+
+/*
+	Creates the collector used for crawling, restricted to the domain.
+	With insecure the certificate of the server is not verified.
+*/
+func NewCollector(domain string, insecure bool) *colly.Collector {
+	c := colly.NewCollector(
+		colly.AllowedDomains(domain),
+	)
 
+	if insecure {
+		c.WithTransport(&http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+	}
+	return c
 }
 
 /*
 
 	Deconstruct given url to relative path, while deligating by filetype to different subfolders.
+	The names are taken from the path of the canonical url, only the page at the url of the site (i.e. https://host/wp/) becomes index.html.
+	The query of the url is part of the name (i.e. page_id-42.html for ?page_id=42), urls that would share a file get numbered files.
 
 */
 func createFileLinks(pages map[string]string, url string) error {
 
-	domain, err := urlToDomain(url)
+	base, err := neturl.Parse(url)
 	if err != nil {
 		return err
 	}
 
-	for link, filetype := range pages {
+	links := make([]string, 0, len(pages))
+	for link := range pages {
+		links = append(links, link)
+	}
+	sort.Strings(links) // The same urls get the same files in every run
+	used := make(map[string]bool)
+
+	for _, link := range links {
+		filetype := pages[link]
+		u, err := neturl.Parse(link)
+		if err != nil {
+			return err
+		}
+		fragments := delete_empty(strings.Split(u.Path, "/"))
+		if len(fragments) == 0 {
+			fragments = []string{"index"}
+		}
+
+		rel_link := ""
 		if strings.Contains(filetype, "text/html") {
-			fragments := strings.Split(link, "/")
-			fragments = delete_empty(fragments)
-			filename := fragments[len(fragments)-1] + ".html"
-			if strings.Contains(filename, domain) {
-				filename = "index.html"
+			filename := queryName(pageName(u, base), u.RawQuery)
+			if filename == "" {
+				filename = "index"
 			}
-			pages[link] = "./" + filename
+			rel_link = "./" + filename + ".html"
 		} else if strings.Contains(filetype, "text/css") {
-			filename := ""
-			if strings.Contains(link, "plugins") || strings.Contains(link, "wp-includes") {
-				fragments := strings.Split(link, "/")
-				fragments = delete_empty(fragments)
+			filename := fragments[len(fragments)-1]
+			if (strings.Contains(link, "plugins") || strings.Contains(link, "wp-includes")) && len(fragments) > 1 {
 				filename = fragments[len(fragments)-2] + "-" + fragments[len(fragments)-1]
-			} else {
-				fragments := strings.Split(link, "/")
-				fragments = delete_empty(fragments)
-				filename = fragments[len(fragments)-1]
 			}
-			filename = strings.Split(filename, "?")[0]
 			if strings.Contains(filename, "min") {
-				filename = strings.Split(filename, ".")[0] + ".min.css"
+				filename = queryName(strings.Split(filename, ".")[0], u.RawQuery) + ".min.css"
 			} else {
-				filename = strings.Split(filename, ".")[0] + ".css"
+				filename = queryName(strings.Split(filename, ".")[0], u.RawQuery) + ".css"
 			}
-			pages[link] = "./css/" + filename
+			rel_link = "./css/" + filename
 		} else if strings.Contains(filetype, "javascript") {
-			filename := ""
-			if strings.Contains(link, "plugins") || strings.Contains(link, "wp-includes") {
-				fragments := strings.Split(link, "/")
-				fragments = delete_empty(fragments)
+			filename := fragments[len(fragments)-1]
+			if (strings.Contains(link, "plugins") || strings.Contains(link, "wp-includes")) && len(fragments) > 1 {
 				filename = fragments[len(fragments)-2] + "-" + fragments[len(fragments)-1]
-			} else {
-				fragments := strings.Split(link, "/")
-				fragments = delete_empty(fragments)
-				filename = fragments[len(fragments)-1]
 			}
-			filename = strings.Split(filename, "?")[0]
 			filename = strings.Replace(filename, ".", "-", strings.Count(filename, ".")-1)
-			pages[link] = "./js/" + filename
+			ext := path.Ext(filename)
+			rel_link = "./js/" + queryName(strings.TrimSuffix(filename, ext), u.RawQuery) + ext
 		} else {
-			fragments := strings.Split(link, "/")
-			fragments = delete_empty(fragments)
 			filename := fragments[len(fragments)-1]
-			pages[link] = "./assets/" + filename
+			ext := path.Ext(filename)
+			rel_link = "./assets/" + queryName(strings.TrimSuffix(filename, ext), u.RawQuery) + ext
 		}
+		pages[link] = uniqueName(rel_link, used)
 	}
 	return nil
 
@@ -237,110 +565,281 @@ func createFileLinks(pages map[string]string, url string) error {
 	Fetch all pages from the given list of pages and create the files
 	Reomve all URLs from the files specified in the remove list
 	Replace all absolut links given in the pages list with relative links, which are the second parameter in the pages list
+	Root-relative links in quotes (i.e. "/wp/about/" of WordPress in a subpath) are replaced as well, except in scripts
+	Replace the literals found by analyseScripts in the scripts with relative links as well
+	Only textual files are rewritten, media files are streamed to disk unchanged
+	The files are read from the store of the crawl, they are not downloaded again
 */
-func fetchPages(pages, remove, fonts map[string]string, path string) error {
+func fetchPages(pages, remove, literals, fonts map[string]string, path string, store *responseStore) error {
+	ordered_key_list_pages := orderMapKeys(pages)
+	ordered_key_list_remove := orderMapKeys(remove)
 
 	for link, rel_link := range pages {
-		resp, err := http.Get(link)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
+		if err := fetchPage(link, rel_link, pages, remove, literals, fonts, path, store, ordered_key_list_pages, ordered_key_list_remove); err != nil {
 			return err
 		}
-		resp_body := string(body)
+	}
+	return nil
 
-		ordered_key_list_pages := orderMapKeys(pages)
-		ordered_key_list_remove := orderMapKeys(remove)
+}
 
-		// Remove all URLs from the files specified in the remove list
-		for _, key := range ordered_key_list_remove {
-			resp_body = strings.ReplaceAll(resp_body, key, "")
-		}
+/*
+	Writes one file of fetchPages, the stored body is closed before the next file is written
+*/
+func fetchPage(link, rel_link string, pages, remove, literals, fonts map[string]string, path string, store *responseStore, ordered_key_list_pages, ordered_key_list_remove []string) error {
+	entry, err := store.get(link)
+	if err != nil {
+		return err
+	}
+	stored, err := store.open(entry)
+	if err != nil {
+		return err
+	}
+	defer stored.Close()
 
-		// Replace all absolut links given in the pages list with relative links, which are at this time the second parameter in the pages list
-		for _, key := range ordered_key_list_pages {
-			rep_rel_link := pages[key]
+	file_path := path + strings.Replace(rel_link, "./", "/", -1)
+	filetype := entry.ContentType // Get the filetype of the response
 
-			if strings.Contains(rel_link, "css") || strings.Contains(rel_link, "js") || strings.Contains(rel_link, "assets") { // If the link is in a subfolder we need to add a directory change to the relative link
-				rep_rel_link = strings.ReplaceAll(rep_rel_link, "./", "./../")
-				resp_body = strings.ReplaceAll(resp_body, key, rep_rel_link)
-			} else {
-				resp_body = strings.ReplaceAll(resp_body, key, rep_rel_link)
+	if !isText(filetype) { // Images, PDFs and videos could be corrupted by the replacements, and do not have to fit into memory
+		return streamToFile(stored, file_path)
+	}
+
+	body, err := ioutil.ReadAll(stored)
+	if err != nil {
+		return err
+	}
+	resp_body := string(body)
+
+	resp_body = replaceReferences(resp_body, link, rel_link, filetype, pages, store)
+
+	// Remove all URLs from the files specified in the remove list
+	for _, key := range ordered_key_list_remove {
+		resp_body = strings.ReplaceAll(resp_body, key, "")
+	}
+
+	// Replace all absolut links given in the pages list with relative links, which are at this time the second parameter in the pages list
+	for _, key := range ordered_key_list_pages {
+		rep_rel_link := relativeTo(rel_link, pages[key])
+		resp_body = strings.ReplaceAll(resp_body, key, rep_rel_link)
+
+		if root_rel_link := rootRelative(key); root_rel_link != "" && !strings.HasPrefix(rel_link, "./js/") { // Scripts compare strings like "/wp/" with paths at runtime
+			for _, quote := range []string{`"`, `'`} {
+				resp_body = strings.ReplaceAll(resp_body, quote+root_rel_link+quote, quote+rep_rel_link+quote)
 			}
 		}
+	}
 
-		filetype := resp.Header.Get("Content-Type")                                                                     // Get the filetype of the response
-		if filetype == "image/svg+xml" || (!strings.Contains(filetype, "json") && !strings.Contains(filetype, "xml")) { // Skipping JSON and XML files, as JSON is the response from the WP REST API, and XML the response of the legacy XML-RPC API
-			if strings.Contains(path, "svg") {
-				for key, value := range fonts {
-					if strings.Contains(resp_body, key) {
-						resp_body = strings.ReplaceAll(resp_body, key, value)
-					}
+	if filetype == "image/svg+xml" || (!strings.Contains(filetype, "json") && !strings.Contains(filetype, "xml")) { // Skipping JSON and XML files, as JSON is the response from the WP REST API, and XML the response of the legacy XML-RPC API
+		if strings.Contains(path, "svg") {
+			for key, value := range fonts {
+				if strings.Contains(resp_body, key) {
+					resp_body = strings.ReplaceAll(resp_body, key, value)
 				}
 			}
 		}
+	}
+
+	resp_body = strings.ReplaceAll(resp_body, "href=\"/#", "href=\"#") // Fix links to anchors
+
+	if strings.HasPrefix(rel_link, "./js/") {
+		resp_body = replaceScriptLiterals(resp_body, literals, pages)
+	}
+
+	if len(resp_body) > 0 {
+		return ioutil.WriteFile(file_path, []byte(resp_body), 0644)
+	}
+	return nil
+}
 
-		resp_body = strings.ReplaceAll(resp_body, "href=\"/#", "href=\"#") // Fix links to anchors
+/*
+	Name of the file of a page without the extension, empty for the page at the url of the site (base).
+	Index files are the page of their folder (i.e. /blog/index.php is blog), like on the server.
+	The name ends at the first dot, the upload names the pages the same way.
+*/
+func pageName(u, base *neturl.URL) string {
+	fragments := delete_empty(strings.Split(u.Path, "/"))
+	if n := len(fragments); n > 0 && strings.Split(fragments[n-1], ".")[0] == "index" {
+		fragments = fragments[:n-1]
+	}
+	if u.Host == base.Host && "/"+strings.Join(fragments, "/") == "/"+strings.Join(delete_empty(strings.Split(base.Path, "/")), "/") {
+		return ""
+	}
+	if len(fragments) == 0 { // The root of the host, WordPress is in a subpath
+		return "root"
+	}
+	if name := strings.Split(fragments[len(fragments)-1], ".")[0]; name != "" {
+		return name
+	}
+	return "page"
+}
 
-		file_path := path + strings.Replace(rel_link, "./", "/", -1)
+/*
+	Link to the file target from the file rel_link, both relative to the project
+*/
+func relativeTo(rel_link, target string) string {
+	if strings.HasPrefix(rel_link, "./css/") || strings.HasPrefix(rel_link, "./js/") || strings.HasPrefix(rel_link, "./assets/") { // If the link is in a subfolder we need to add a directory change to the relative link, pages like ./index-format-json.html are not
+		return strings.ReplaceAll(target, "./", "./../")
+	}
+	return target
+}
 
-		if len(resp_body) > 0 {
-			err = ioutil.WriteFile(file_path, []byte(resp_body), 0644)
-			if err != nil {
-				return err
+/*
+	Replaces the references of html pages and stylesheets with the files they lead to.
+	The references are resolved against the page and canonicalized, so relative links, links with tracking parameters and redirected links (i.e. /about for /about/) are found as well.
+	Only the values of attributes, url() and @import are replaced, the same string in a script stays untouched.
+*/
+func replaceReferences(body, link, rel_link, filetype string, pages map[string]string, store *responseStore) string {
+	var raws []string
+	var contexts [][2]string // Text before and after the reference
+	if strings.Contains(filetype, "text/html") {
+		for _, reference := range refs.Extract(body) {
+			if reference.Attribute != "style" { // url() in style attributes are no attribute values
+				raws = append(raws, reference.Raw)
 			}
 		}
+		contexts = [][2]string{{`="`, `"`}, {`='`, `'`}}
+	} else if strings.Contains(filetype, "text/css") {
+		raws = refs.ExtractCSS(body)
+		contexts = [][2]string{{`(`, `)`}, {`"`, `"`}, {`'`, `'`}} // url(), quoted url() and @import without url()
 	}
-	return nil
 
+	replaced := make(map[string]bool)
+	for _, raw := range raws {
+		if replaced[raw] {
+			continue
+		}
+		replaced[raw] = true
+		target := canonicalURL(absoluteURL(link, html.UnescapeString(raw)))
+		if target == "" {
+			continue
+		}
+		hops := store.redirects(target)
+		file, ok := pages[hops[len(hops)-1]]
+		if !ok {
+			continue
+		}
+		if i := strings.Index(raw, "#"); i >= 0 { // Anchors on the page
+			file += raw[i:]
+		}
+		for _, context := range contexts {
+			body = strings.ReplaceAll(body, context[0]+raw+context[1], context[0]+relativeTo(rel_link, file)+context[1])
+		}
+	}
+	return body
+}
+
+/*
+	Root-relative form of an absolute link (path and query), empty for the root of the host, which is too common a string to replace
+*/
+func rootRelative(link string) string {
+	u, err := neturl.Parse(link)
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return ""
+	}
+	return u.RequestURI()
+}
+
+/*
+	Content types whose links are rewritten: html, css, scripts, json and xml (including svg)
+*/
+func isText(filetype string) bool {
+	filetype = strings.ToLower(filetype)
+	if strings.HasPrefix(filetype, "text/") {
+		return true
+	}
+	for _, textual := range []string{"javascript", "ecmascript", "json", "xml"} {
+		if strings.Contains(filetype, textual) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Copies the body to the file, empty files are not created
+*/
+func streamToFile(body io.Reader, file_path string) error {
+	file, err := os.Create(file_path)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written == 0 {
+		return os.Remove(file_path)
+	}
+	return err
 }
 
 /*
-	Try to remove query information and anchors from url
+	Creates the default header and footer templates (templates/header.html and templates/footer.html) from the theme of the front page.
+	WordPress themes render the same site header and footer on every page, when preparing the pages they get replaced with the calls of the templates.
+	Existing templates are kept, they might have been edited.
 */
-func sanitize_url(url string) string {
-	if strings.Contains(url, "?") {
-		url = strings.Split(url, "?")[0]
+func createTemplates(path string) error {
+	content, err := ioutil.ReadFile(path + "/index.html")
+	if err != nil {
+		return nil // Nothing to extract the theme from
+	}
+
+	parts := make(map[string]string)
+	if header := headerRegEx.FindString(string(content)); header != "" { // The first header is the one of the site, articles can have their own
+		parts["header"] = header
 	}
-	if strings.Contains(url, "#") {
-		url = strings.Split(url, "#")[0]
+	if footers := footerRegEx.FindAllString(string(content), -1); len(footers) > 0 { // The last footer is the one of the site
+		parts["footer"] = footers[len(footers)-1]
 	}
-	if strings.Contains(url, "&") {
-		url = strings.Split(url, "&")[0]
+	if len(parts) == 0 {
+		return nil
 	}
-	if strings.Contains(url, "=") {
-		url = strings.Split(url, "=")[0]
+
+	if err := makeDir(path + "/templates"); err != nil {
+		return err
 	}
-	if len(delete_empty(strings.Split(url, "/"))) == 2 {
-		if url[len(url)-1] != '/' {
-			url = url + "/"
+	for name, part := range parts {
+		file := path + "/templates/" + name + ".html"
+		if _, err := os.Stat(file); err == nil {
+			continue
+		}
+		if err := ioutil.WriteFile(file, []byte(part), 0644); err != nil {
+			return err
 		}
 	}
-	return url
+	return nil
 }
 
+var (
+	headerRegEx = regexp.MustCompile(`(?s)<header\b[^>]*>.*?</header>`)
+	footerRegEx = regexp.MustCompile(`(?s)<footer\b[^>]*>.*?</footer>`)
+)
+
 /*
 
 	Try to convert a URL to a domain name.
+	The domain is the host of the url including the port, i.e. localhost:8080 or [::1]:8443.
 
 */
 func urlToDomain(URL string) (string, error) {
-	split := strings.Split(URL, ":")
-	if len(split) == 1 || !strings.Contains(split[0], "http") {
-		return "", errors.New("no protocol information found")
+	u, err := neturl.Parse(URL)
+	if err != nil {
+		return "", err
 	}
-	if len(split) > 2 {
-		return "", errors.New("please remove port information")
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("no protocol information found")
 	}
-	domain := split[1]
-	split = strings.Split(domain, "/")
-	if len(split) < 3 {
+	if u.Host == "" {
 		return "", errors.New("malformed url")
 	}
-	return split[2], nil
+	return u.Host, nil
+}
+
+/*
+	Name of the folder for a domain, colons and the brackets of IPv6 addresses are not allowed in folder names on Windows
+*/
+func hostDir(domain string) string {
+	return strings.NewReplacer("[", "", "]", "", ":", "_").Replace(domain)
 }
 
 /*
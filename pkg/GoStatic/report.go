@@ -0,0 +1,138 @@
+package GoGEMgostatic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	Record of the crawl for debugging: every visited url with its status, redirects, content type, size, the file it was saved to and the page referencing it.
+	Written to crawl-report.json and crawl-report.txt in the project, the file handling leaves them out when preparing and uploading the pages.
+*/
+
+/*
+	Visited url of the crawl. Redirects are the urls that were redirected to URL, the first one was referenced.
+	Bucket is pages for files of the project, remove for urls removed from the files (JSON and XML APIs) and failed for everything else.
+*/
+type CrawlEntry struct {
+	URL         string   `json:"url"`
+	Status      int      `json:"status"`
+	Redirects   []string `json:"redirects,omitempty"`
+	ContentType string   `json:"contentType,omitempty"`
+	Size        int64    `json:"size"`
+	File        string   `json:"file,omitempty"` // Path in the project, see createFileLinks
+	Referrer    string   `json:"referrer,omitempty"`
+	Bucket      string   `json:"bucket"`
+	Error       string   `json:"error,omitempty"`
+}
+
+const (
+	crawlReportJSON = "crawl-report.json"
+	crawlReportText = "crawl-report.txt"
+)
+
+type crawlReport struct {
+	mutex     sync.Mutex // The callbacks of the crawl run in parallel
+	entries   map[string]*CrawlEntry
+	referrers map[string]string   // Url -> first page referencing it
+	chains    map[string][]string // Url -> urls redirected to it
+}
+
+func newCrawlReport() *crawlReport {
+	return &crawlReport{entries: make(map[string]*CrawlEntry), referrers: make(map[string]string), chains: make(map[string][]string)}
+}
+
+/*
+	Records the page referencing link, only the first one is kept
+*/
+func (r *crawlReport) referenced(link, page string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.referrers[link]; !ok {
+		r.referrers[link] = page
+	}
+}
+
+func (r *crawlReport) redirected(from, to string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.chains[to] = append(append([]string{}, r.chains[from]...), from)
+}
+
+/*
+	Records the response of link, err is set for requests that failed without a response
+*/
+func (r *crawlReport) visited(link string, status int, contentType string, size int64, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entry := &CrawlEntry{URL: link, Status: status, ContentType: contentType, Size: size, Redirects: r.chains[link]}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.entries[link] = entry
+}
+
+/*
+	Writes the report to the project, after createFileLinks assigned the files to the pages
+*/
+func (r *crawlReport) write(path string, pages, remove map[string]string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	links := make([]string, 0, len(r.entries))
+	for link := range r.entries {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	entries := make([]*CrawlEntry, 0, len(links))
+	var text strings.Builder
+	for _, link := range links {
+		entry := r.entries[link]
+		referenced := link
+		if len(entry.Redirects) > 0 {
+			referenced = entry.Redirects[0]
+		}
+		entry.Referrer = r.referrers[referenced]
+		if file, ok := pages[link]; ok {
+			entry.Bucket = "pages"
+			entry.File = file
+		} else if _, ok := remove[link]; ok {
+			entry.Bucket = "remove"
+		} else {
+			entry.Bucket = "failed"
+		}
+		entries = append(entries, entry)
+
+		text.WriteString(fmt.Sprintf("%d %s [%s]", entry.Status, entry.URL, entry.Bucket))
+		if entry.ContentType != "" {
+			text.WriteString(fmt.Sprintf(" %s, %d bytes", entry.ContentType, entry.Size))
+		}
+		if entry.File != "" {
+			text.WriteString(" -> " + entry.File)
+		}
+		text.WriteString("\n")
+		for _, redirect := range entry.Redirects {
+			text.WriteString("\tredirected from " + redirect + "\n")
+		}
+		if entry.Referrer != "" {
+			text.WriteString("\treferenced by " + entry.Referrer + "\n")
+		}
+		if entry.Error != "" {
+			text.WriteString("\terror: " + entry.Error + "\n")
+		}
+	}
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+"/"+crawlReportJSON, content, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+"/"+crawlReportText, []byte(text.String()), 0644)
+}
@@ -0,0 +1,178 @@
+package GoGEMgostatic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+	Opt-in analysis of the scripts of the page: themes and sliders hard-code images or JSON files in string literals (i.e. "/wp-content/uploads/slide.jpg"),
+	which the crawler never finds, because they are not referenced in the HTML.
+	Literals pointing to files of the same domain are downloaded with the other assets and replaced in the scripts, everything that only looks like it is reported.
+	JSON and XML files are removed like in the crawl, iGEM does not accept them as uploads.
+*/
+
+/*
+	String literal of a script that looks like a file, but could not be resolved with certainty
+*/
+type UncertainLiteral struct {
+	Script  string `json:"script"`
+	Literal string `json:"literal"`
+	Reason  string `json:"reason"`
+}
+
+const scriptReportFile = "script-assets.json" // Left out by the file handling like the crawl report
+
+var (
+	literalRegEx   = regexp.MustCompile(`"((?:[^"\\\n]|\\.)*)"|'((?:[^'\\\n]|\\.)*)'`)
+	assetExtRegEx  = regexp.MustCompile(`(?i)\.(jpe?g|png|gif|svg|webp|ico|json|mp4|webm|ogg|mp3|wav|woff2?|ttf|eot|otf|pdf)$`)
+	uploadDirRegEx = regexp.MustCompile(`/wp-content/`)
+)
+
+/*
+	Finds the literals of all scripts in pages that point to files of the site, and adds these files to pages, or to remove for JSON and XML files.
+	Returns the literals as they are written in the scripts, mapped to the absolute url of the file, and the literals it was unsure about.
+	Literals of removed files map to urls that are not in pages, they are replaced with empty strings.
+*/
+func analyseScripts(pages, remove map[string]string, site string, store *responseStore) (map[string]string, []UncertainLiteral, error) {
+	base, err := neturl.Parse(site)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	literals := make(map[string]string)
+	sources := make(map[string]string) // Literal -> script it was found in first
+	var uncertain []UncertainLiteral
+
+	for _, script := range orderMapKeys(pages) {
+		if !strings.Contains(pages[script], "javascript") {
+			continue
+		}
+		entry, err := store.get(script) // Already crawled
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := store.read(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, match := range literalRegEx.FindAllStringSubmatch(string(body), -1) {
+			literal := match[1] + match[2] // Only one of the quotes matched
+			link, reason := resolveLiteral(literal, base)
+			if reason != "" {
+				uncertain = append(uncertain, UncertainLiteral{Script: script, Literal: literal, Reason: reason})
+			}
+			if link != "" {
+				literals[literal] = link
+				if sources[literal] == "" {
+					sources[literal] = script
+				}
+			}
+		}
+	}
+
+	// Request the files the crawler has not found, to learn their content type
+	for literal, link := range literals {
+		if _, ok := pages[link]; ok {
+			continue
+		}
+		if _, ok := remove[link]; ok {
+			continue
+		}
+		entry, err := store.get(link)
+		if err == errNotCached {
+			uncertain = append(uncertain, UncertainLiteral{Script: sources[literal], Literal: literal, Reason: "file was not stored, crawl without --offline to download it"})
+			delete(literals, literal)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if entry.Status != 200 {
+			uncertain = append(uncertain, UncertainLiteral{Script: sources[literal], Literal: literal, Reason: fmt.Sprintf("file returned %d", entry.Status)})
+			delete(literals, literal)
+			continue
+		}
+		if entry.ContentType == "image/svg+xml" || (!strings.Contains(entry.ContentType, "json") && !strings.Contains(entry.ContentType, "xml")) { // Skipping JSON and XML files like the crawl
+			pages[link] = entry.ContentType
+		} else {
+			remove[link] = ""
+		}
+	}
+
+	sort.SliceStable(uncertain, func(i, j int) bool {
+		return uncertain[i].Script < uncertain[j].Script
+	})
+	return literals, uncertain, nil
+}
+
+/*
+	Returns the absolute url of a literal that certainly is a file of the site, or the reason why the literal is uncertain.
+	Both are empty for literals that are no files of the site at all.
+*/
+func resolveLiteral(literal string, base *neturl.URL) (string, string) {
+	path := strings.ReplaceAll(literal, `\/`, "/") // JSON encoded by wp_localize_script
+	file := path
+	if i := strings.IndexAny(file, "?#"); i >= 0 {
+		file = file[:i]
+	}
+	isAsset := assetExtRegEx.MatchString(file)
+	if !isAsset && !uploadDirRegEx.MatchString(path) {
+		return "", ""
+	}
+	if strings.ContainsAny(path, " <>{}") || strings.Contains(path, "${") {
+		return "", "looks like a template, the url is built at runtime"
+	}
+
+	u, err := neturl.Parse(path)
+	if err != nil {
+		return "", "not a valid url"
+	}
+	if u.Host != "" && u.Host != base.Host {
+		return "", "" // Other servers are not part of the clone
+	}
+	if !isAsset {
+		return "", "points to the site without a file extension, probably a prefix the url is built from at runtime"
+	}
+	if u.Host == "" && !strings.HasPrefix(u.Path, "/") {
+		return "", "relative path, it is resolved against the page the script runs on"
+	}
+	return canonicalURL(base.ResolveReference(u).String()), ""
+}
+
+/*
+	Replaces the literals of the script with the relative links of their files, scripts are stored in ./js, the files in ./assets
+	Literals of files that are not in pages (removed JSON and XML files) are replaced with empty strings.
+*/
+func replaceScriptLiterals(body string, literals, pages map[string]string) string {
+	for _, literal := range orderMapKeys(literals) {
+		rel_link := ""
+		if page, ok := pages[literals[literal]]; ok {
+			rel_link = strings.ReplaceAll(page, "./", "./../")
+		}
+		for _, quote := range []string{`"`, `'`} {
+			body = strings.ReplaceAll(body, quote+literal+quote, quote+rel_link+quote)
+		}
+	}
+	return body
+}
+
+/*
+	Writes the uncertain literals to script-assets.json in the project and prints them
+*/
+func reportScriptLiterals(path string, uncertain []UncertainLiteral) error {
+	for _, literal := range uncertain {
+		println(fmt.Sprintf("Unsure about %q in %s: %s", literal.Literal, literal.Script, literal.Reason))
+	}
+	content, err := json.MarshalIndent(uncertain, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+"/"+scriptReportFile, content, 0644)
+}
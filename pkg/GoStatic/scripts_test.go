@@ -0,0 +1,61 @@
+package GoGEMgostatic
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnalyseScriptsRemovesJSON(t *testing.T) {
+	store, err := openStore(t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := map[string]string{
+		"https://example.org/wp-content/themes/slider.js":    "application/javascript",
+		"https://example.org/wp-content/uploads/slide.jpg":   "image/jpeg",
+		"https://example.org/wp-content/uploads/slides.json": "application/json",
+		"https://example.org/wp-content/uploads/arrow.svg":   "image/svg+xml",
+	}
+	bodies := map[string]string{
+		"https://example.org/wp-content/themes/slider.js": `var slides = ["/wp-content/uploads/slide.jpg", "/wp-content/uploads/slides.json", "/wp-content/uploads/arrow.svg"];`,
+	}
+	for url, contentType := range responses {
+		headers := http.Header{}
+		headers.Set("Content-Type", contentType)
+		if _, err := store.save(url, 200, headers, strings.NewReader(bodies[url])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages := map[string]string{"https://example.org/wp-content/themes/slider.js": "application/javascript"}
+	remove := make(map[string]string)
+	literals, uncertain, err := analyseScripts(pages, remove, "https://example.org/", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uncertain) != 0 {
+		t.Errorf("uncertain = %v, want none", uncertain)
+	}
+	for _, url := range []string{"https://example.org/wp-content/uploads/slide.jpg", "https://example.org/wp-content/uploads/arrow.svg"} {
+		if _, ok := pages[url]; !ok {
+			t.Errorf("%s is not in pages", url)
+		}
+	}
+	for _, url := range []string{"https://example.org/wp-content/uploads/slides.json"} {
+		if _, ok := pages[url]; ok {
+			t.Errorf("%s is in pages, it is no upload", url)
+		}
+		if _, ok := remove[url]; !ok {
+			t.Errorf("%s is not in remove", url)
+		}
+	}
+
+	pages["https://example.org/wp-content/uploads/slide.jpg"] = "./assets/slide.jpg"
+	pages["https://example.org/wp-content/uploads/arrow.svg"] = "./assets/arrow.svg"
+	got := replaceScriptLiterals(bodies["https://example.org/wp-content/themes/slider.js"], literals, pages)
+	want := `var slides = ["./../assets/slide.jpg", "", "./../assets/arrow.svg"];`
+	if got != want {
+		t.Errorf("replaceScriptLiterals = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package GoGEMgostatic
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+	Stores the responses of the crawl on disk, so every file is downloaded only once: fetchPages reads the crawled bodies instead of requesting them again.
+	The store is kept between runs, the next crawl asks the server with ETag and Last-Modified if a file changed and reuses the stored body on 304 Not Modified.
+*/
+type responseStore struct {
+	dir       string
+	offline   bool   // Nothing is requested, only stored responses are used
+	userAgent string // Sent by the requests of the store, like the crawler
+	client    *http.Client
+	mutex     sync.Mutex
+	entries   map[string]*storedResponse // Url -> response
+}
+
+/*
+	Stored response, the body is stored in its own file in the directory of the store
+*/
+type storedResponse struct {
+	URL          string `json:"url"`
+	Status       int    `json:"status"`
+	ContentType  string `json:"contentType"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"` // Target of a redirect
+	File         string `json:"file"`
+	current      bool   // Requested or validated in this run
+}
+
+const storeIndex = "index.json"
+
+var errNotCached = errors.New("notCached")
+
+/*
+	Opens the store in dir, responses of earlier runs are loaded from its index.
+	Requests of the store itself (i.e. for files found by the script analysis) ignore certificate warnings with insecure, like the crawler.
+	An offline store never requests anything, it fails if there are no stored responses.
+*/
+func openStore(dir string, insecure, offline bool) (*responseStore, error) {
+	if err := makeDir(dir); err != nil {
+		return nil, err
+	}
+	store := &responseStore{dir: dir, offline: offline, client: &http.Client{}, entries: make(map[string]*storedResponse)}
+	if insecure {
+		store.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, storeIndex))
+	if err == nil {
+		if err := json.Unmarshal(content, &store.entries); err != nil { // A broken index only costs the conditional requests
+			store.entries = make(map[string]*storedResponse)
+		}
+	}
+	if offline && len(store.entries) == 0 {
+		return nil, errors.New("no stored crawl in " + dir + ", run once without --offline")
+	}
+	return store, nil
+}
+
+/*
+	Default directory of the store for a domain, GoGEM/crawl/[domain] in the cache directory of the user
+*/
+func storeDir(domain string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "GoGEM", "crawl", hostDir(domain)), nil
+}
+
+/*
+	Adds the validators of the stored response to the headers of a request, the server answers with 304 if the file did not change
+*/
+func (s *responseStore) conditional(url string, headers http.Header) {
+	s.mutex.Lock()
+	entry, ok := s.entries[url]
+	s.mutex.Unlock()
+	if !ok || !s.hasBody(entry) {
+		return
+	}
+	if entry.ETag != "" {
+		headers.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		headers.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+/*
+	Stores a response of the server. On 304 the stored response is returned instead.
+	Other responses than 2xx are returned without storing them.
+*/
+func (s *responseStore) save(url string, status int, headers http.Header, body io.Reader) (*storedResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if status == http.StatusNotModified {
+		entry, ok := s.entries[url]
+		if !ok {
+			return nil, errors.New("notModifiedWithoutStoredResponse")
+		}
+		entry.current = true
+		return entry, nil
+	}
+	entry := &storedResponse{URL: url, Status: status, ContentType: headers.Get("Content-Type"), ETag: headers.Get("ETag"), LastModified: headers.Get("Last-Modified"), current: true}
+	if status < 200 || status >= 300 {
+		return entry, nil
+	}
+
+	hash := sha256.Sum256([]byte(url))
+	entry.File = hex.EncodeToString(hash[:])
+	file, err := os.Create(filepath.Join(s.dir, entry.File))
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.entries[url] = entry
+	return entry, nil
+}
+
+/*
+	Stores a redirect of the crawl, the response of the target is stored by save
+*/
+func (s *responseStore) redirect(url string, status int, location string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[url] = &storedResponse{URL: url, Status: status, Location: location, current: true}
+}
+
+/*
+	Returns the response for url, requesting it (conditionally) if it was not requested in this run yet. Redirects are followed.
+	Offline every stored response is used, errNotCached is returned for the others.
+*/
+func (s *responseStore) get(url string) (*storedResponse, error) {
+	s.mutex.Lock()
+	entry, ok := s.entries[url]
+	for hops := 0; ok && entry.Location != "" && hops < 10; hops++ {
+		entry, ok = s.entries[entry.Location]
+	}
+	if ok && s.offline {
+		entry.current = true
+	}
+	s.mutex.Unlock()
+	if ok && entry.current {
+		return entry, nil
+	}
+	if s.offline {
+		return nil, errNotCached
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.userAgent != "" {
+		req.Header.Set("User-Agent", s.userAgent)
+	}
+	s.conditional(url, req.Header)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return s.save(url, resp.StatusCode, resp.Header, resp.Body)
+}
+
+/*
+	Opens the stored body of a response
+*/
+func (s *responseStore) open(entry *storedResponse) (*os.File, error) {
+	if entry.File == "" {
+		return nil, errors.New("noStoredBody")
+	}
+	return os.Open(filepath.Join(s.dir, entry.File))
+}
+
+/*
+	Reads the stored body of a response
+*/
+func (s *responseStore) read(entry *storedResponse) ([]byte, error) {
+	file, err := s.open(entry)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+/*
+	Size of the stored body, 0 without one
+*/
+func (s *responseStore) size(entry *storedResponse) int64 {
+	if entry.File == "" {
+		return 0
+	}
+	info, err := os.Stat(filepath.Join(s.dir, entry.File))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+/*
+	Urls of the stored redirects starting at url, the last one is the url after the redirects
+*/
+func (s *responseStore) redirects(url string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	urls := []string{url}
+	entry, ok := s.entries[url]
+	for hops := 0; ok && entry.Location != "" && hops < 10; hops++ {
+		urls = append(urls, entry.Location)
+		entry, ok = s.entries[entry.Location]
+	}
+	return urls
+}
+
+func (s *responseStore) hasBody(entry *storedResponse) bool {
+	if entry.File == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(s.dir, entry.File))
+	return err == nil
+}
+
+/*
+	Writes the index of the store, so the next run can make conditional requests
+*/
+func (s *responseStore) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, storeIndex), content, 0644)
+}
@@ -1,9 +1,22 @@
 package gogemhandler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	api "github.com/Jackd4w/GoGEM-WikiAPI"
 )
@@ -17,9 +30,19 @@ type Handler struct {
 	loginURL        string
 	logoutURL       string
 	prefixURL       string
+	baseURL         string
 	timeout         int
+	username        string
+	password        PasswordFunc
+	persistent      bool          // Session is stored in the session cache and outlives the handler
+	lifetime        time.Duration // Lifetime of the stored session
 }
 
+/*
+	Returns the password of the user, only called if there is no valid cached session and a login is necessary
+*/
+type PasswordFunc func() (string, error)
+
 //TODO move blacklist here, this is the place where all files pass, also this persists for the whole runtime
 
 /*
@@ -27,70 +50,128 @@ type Handler struct {
   Also reduces the information that needs to be passed around.
 
   Save Session for the runtime of the program. Minimize server requests by keeping track of uploaded files during session
+
+  If the user stored a session with Persist (GoGEM login) and it has not expired yet, it is reused instead of logging in.
+  An expired stored session gets replaced transparently by a new login.
 */
-func NewHandler(year, timeout int, username, password, teamname, offset, loginURL, logoutURL, prefixURL string) (*Handler, error) {
+func NewHandler(year, timeout int, username string, password PasswordFunc, teamname, offset, loginURL, logoutURL, prefixURL, baseURL string) (*Handler, error) {
 	handler := new(Handler)
 
 	handler.loginURL = loginURL
 	handler.logoutURL = logoutURL
-	handler.prefixURL = fmt.Sprintf(prefixURL, year)
+	handler.prefixURL = prefixURL
+	if strings.Contains(prefixURL, "%d") { // The year can be left open, i.e. https://%d.igem.org/...
+		handler.prefixURL = fmt.Sprintf(prefixURL, year)
+	}
+	handler.baseURL = baseURL
 	handler.year = year
 	handler.teamname = teamname
 	handler.offset = offset
 	handler.alreadyUploaded = make(map[string]bool)
 	handler.timeout = timeout
+	handler.username = username
+	handler.password = password
 
-	session, err := handler.Login(username, password)
+	cache, err := loadSession(username, handler.BaseURL())
+	if err == nil && time.Now().Before(cache.Expires) && cache.Cookies[handler.BaseURL()+"/"] != nil { // The session has to hold the cookies of the wiki
+		handler.Session = handler.restoreSession(cache)
+		handler.persistent = true
+		handler.lifetime = cache.Lifetime
+		return handler, nil
+	}
+
+	pw, err := password()
+	if err != nil {
+		return nil, err
+	}
+	session, err := handler.Login(username, pw)
 	if err != nil {
 		return nil, err
 	}
 
 	handler.Session = session
 
+	if cache != nil { // The stored session expired or was incomplete, store the new one instead
+		if !time.Now().Before(cache.Expires) {
+			println("Stored session expired, logged in again")
+		}
+		handler.persistent = true
+		if err := handler.Persist(cache.Lifetime); err != nil {
+			return nil, err
+		}
+	}
+
 	return handler, nil
 }
 
 /*
-	Wrappes the Login function in the API package
+	Wrappes the Login function in the API package, the client of the API has no timeout of its own
 */
-func (h Handler) Login(username, password string) (*http.Client, error) {
-	session, err := api.Login(username, password, h.loginURL, h.timeout)
+func (h *Handler) Login(username, password string) (*http.Client, error) {
+	session, err := api.Login(username, password, h.loginURL)
 	if err != nil {
 		return nil, err
 	}
+	session.Timeout = time.Duration(h.timeout) * time.Second
 	return session, nil
 }
 
 /*
-	Wrappes the Logout function in the API package
+	Wrappes the Logout function in the API package, also removes the stored session
 */
-func (h Handler) Logout() error {
+func (h *Handler) Logout() error {
+	if h.persistent {
+		if err := ForgetSession(h.username, h.BaseURL()); err != nil {
+			return err
+		}
+	}
 	return api.Logout(h.Session, h.logoutURL)
 }
 
 /*
-	Wrappes the Upload function in the API package, also performs a check if a session is open and provides necessary metadata
+	Ends the handler: Logs out, unless the session is stored and should be used by the next command
 */
-func (h Handler) Upload(filepath, offset string, force bool) (string, error) {
-	if !h.loggedIn() {
-		return "", errors.New("notLoggedIn")
+func (h *Handler) Close() error {
+	if h.persistent {
+		return nil
 	}
-	url, err := api.Upload(h.Session, h.year, h.teamname, filepath, h.offset+offset, false, force)
-	return url, err
+	return h.Logout()
+}
+
+/*
+	Uploads the page at filepath to its url below the offset (see PageURL), on the wiki of the profile (see BaseURL).
+	Returns fileAlreadyUploaded if the page already has this content and force is not set, otherwise the url of the page.
+*/
+func (h *Handler) Upload(filepath, offset string, force bool) (string, error) {
+	content, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	return h.editPage(strings.TrimPrefix(h.PageURL(filepath, offset), "/"), string(content), force)
 }
 
-func (h Handler) Redirect(source, target string) error {
-	_, err := api.Redirect(h.Session, h.year, h.teamname, source, target)
+/*
+	Turns the page source (relative to the team root, "" for the root itself) into a redirect to target (relative to the team root, "/" for the root)
+*/
+func (h *Handler) Redirect(source, target string) error {
+	title := "Team:" + h.teamname
+	if source != "" {
+		title = title + "/" + source
+	}
+	if target != "/" {
+		target = "/" + target
+	}
+	_, err := h.editPage(title, "#REDIRECT[[Team:"+h.teamname+target+"]]", true)
 	return err
 }
 
 /*
-	Wrappes the Upload function in the API package, also performs a check if a session is open and provides necessary metadata.
-	The force parameter is used to force the upload of a file that has already been uploaded.
+	Uploads the media file at filepath to the "File:" page of the team (see FileURL) through Special:Upload of the wiki of the profile.
+	The force parameter is used to force the upload of a file that has already been uploaded, which is determined by the hash in the summary of the last upload.
 	There is a local check if a file has been uploaded during this session, as this method is called on a per file basis and there will be redundant requests
-	Returns the full url to the uploaded file.
+	Returns the url of the "File:" page, the input for GetFileUrl.
 */
-func (h Handler) UploadFile(filepath string, force bool) (string, error) {
+func (h *Handler) UploadFile(filepath string, force bool) (string, error) {
 	if !h.loggedIn() {
 		return "", errors.New("notLoggedIn")
 	}
@@ -101,7 +182,28 @@ func (h Handler) UploadFile(filepath string, force bool) (string, error) {
 
 	// println("Uploading file: " + filepath) // Debugging
 
-	url, err := api.Upload(h.Session, h.year, h.teamname, filepath, h.offset, true, force)
+	content, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(content)
+	summary := "Hash:" + hex.EncodeToString(hash[:]) // Same summary as the uploads of the API
+
+	url := h.FileURL(filepath)
+	err = h.retry(func() error {
+		if !force && h.lastUploadSummary(url) == summary {
+			return errors.New("fileAlreadyUploaded")
+		}
+		payload, err := h.editTokens(h.BaseURL() + "/Special:Upload")
+		if err != nil {
+			return err
+		}
+		payload["wpSourceType"] = "file"
+		payload["wpDestFile"] = strings.TrimPrefix(url, h.BaseURL()+"/File:")
+		payload["wpUploadDescription"] = summary
+		payload["wpIgnoreWarning"] = "1"
+		return h.submitForm(h.BaseURL()+"/Special:Upload", payload, "wpUploadFile", filepath, content)
+	})
 
 	if err == nil {
 		h.alreadyUploaded[filepath] = true
@@ -110,27 +212,363 @@ func (h Handler) UploadFile(filepath string, force bool) (string, error) {
 	return url, err
 }
 
-// Simple Wrapper
-func (h Handler) GetFileUrl(url string) string {
-	res_url, err := api.GetFileUrl(url, h.Session)
-	if err != nil {
-		return ""
-	}
-	return res_url
+/*
+	Returns the url of the media file behind the "File:" page at url (see FileURL).
+	Returns fileNotFound if the page does not link a file, i.e. because the file has not been uploaded.
+*/
+func (h *Handler) GetFileUrl(url string) (string, error) {
+	res_url := ""
+	err := h.retry(func() (err error) {
+		res_url, err = api.GetFileUrl(url, h.Session)
+		if err == nil && res_url == "" {
+			err = errors.New("fileNotFound")
+		}
+		return err
+	})
+	return res_url, err
 }
 
 /*
 Query all Pages from the specified prefix url that have the specified teamname and offset
 */
-func (h Handler) GetAllPages() ([]string, error) {
-	return api.QueryPages(h.prefixURL, h.teamname, h.offset, h.Session)
+func (h *Handler) GetAllPages() ([]string, error) {
+	var pages []string
+	err := h.retry(func() (err error) {
+		pages, err = api.QueryPages(h.prefixURL, h.teamname, h.offset, h.Session)
+		return err
+	})
+	return pages, err
 }
 
 /* //TODO correct: there is a tag that gets added, so the checker can recognize deleted pages (?) Look into API
 Overwrite the specified pageurl with an empty string, effectively deleting the page (also marking it for eventuell cleanup processes from the hoster side due to it having no user content)
 */
-func (h Handler) DeletePage(pageurl string) error {
-	return api.DeletePage(pageurl, h.year, h.Session)
+func (h *Handler) DeletePage(pageurl string) error {
+	_, err := h.editPage(strings.TrimPrefix(pageurl, "/"), `<div class="purged-page-empty"></div>`, true)
+	return err
+}
+
+/*
+Query all Templates of the team, i.e. Template:teamname and every Template below it
+*/
+func (h *Handler) GetAllTemplates() ([]string, error) {
+	var templates []string
+	err := h.retry(func() (err error) {
+		templates, err = h.getAllTemplates()
+		return err
+	})
+	return templates, err
+}
+
+func (h *Handler) getAllTemplates() ([]string, error) {
+	url := fmt.Sprintf("%s&prefix=%s&namespace=10", h.prefixURL, h.teamname) // Namespace 10 is the MediaWiki Template namespace
+	resp, err := h.Session.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("queryFailed")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tableRegEx := regexp.MustCompile(`(?s)<table class="mw-prefixindex-list-table">(.*?)</table>`) // Same table the PrefixIndex query of the API parses
+	hrefRegEx := regexp.MustCompile(`href="(.*?)"`)
+
+	var templates []string
+	table := tableRegEx.FindStringSubmatch(string(body))
+	if table == nil { // No templates found
+		return templates, nil
+	}
+	for _, match := range hrefRegEx.FindAllStringSubmatch(table[1], -1) {
+		templates = append(templates, match[1])
+	}
+	return templates, nil
+}
+
+/*
+	Returns the raw wikitext of the page at pageurl, pageurl is relative to the wiki root (as returned by GetAllPages)
+*/
+func (h *Handler) GetRawPage(pageurl string) (string, error) {
+	content := ""
+	err := h.retry(func() (err error) {
+		content, err = h.getRawPage(pageurl)
+		return err
+	})
+	return content, err
+}
+
+func (h *Handler) getRawPage(pageurl string) (string, error) {
+	resp, err := h.Session.Get(h.BaseURL() + pageurl + "?action=raw")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", errors.New("pageNotFound")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+/*
+	Replaces the content of the page title (relative to the wiki root, i.e. Template:TU_Darmstadt/header) with content, the page is created if it does not exist.
+	Unlike Upload this edits any page, not only the pages of the team namespace.
+	Returns fileAlreadyUploaded if the page already has this content, otherwise the url of the page.
+*/
+func (h *Handler) EditPage(title, content string) (string, error) {
+	return h.editPage(title, content, false)
+}
+
+/*
+	Saves content to the page title through the edit form of MediaWiki, on the wiki of the profile. Unless force is set, pages that already have this content are left alone.
+*/
+func (h *Handler) editPage(title, content string, force bool) (string, error) {
+	if !h.loggedIn() {
+		return "", errors.New("notLoggedIn")
+	}
+	pageurl := h.BaseURL() + "/" + strings.ReplaceAll(title, " ", "_")
+
+	url := ""
+	err := h.retry(func() error {
+		if !force {
+			if current, err := h.getRawPage("/" + strings.ReplaceAll(title, " ", "_")); err == nil && strings.TrimSpace(current) == strings.TrimSpace(content) {
+				return errors.New("fileAlreadyUploaded")
+			}
+		}
+
+		payload, err := h.editTokens(pageurl + "?action=edit")
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256([]byte(content))
+		payload["wpTextbox1"] = content
+		payload["wpSummary"] = "Hash:" + hex.EncodeToString(hash[:]) // Same summary as the uploads of the API
+
+		if err := h.submitForm(pageurl+"?action=submit", payload, "", "", nil); err != nil {
+			return err
+		}
+		url = pageurl
+		return nil
+	})
+	return url, err
+}
+
+/*
+	Posts the fields (and the file content as fileField, if set) as multipart form to url.
+	MediaWiki redirects after a saved edit or upload, everything else is the form with an error.
+*/
+func (h *Handler) submitForm(url string, fields map[string]string, fileField, filename string, content []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if key == fileField {
+			continue
+		}
+		if err := form.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+	if fileField != "" {
+		part, err := form.CreateFormFile(fileField, filepath.Base(filename))
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(content); err != nil {
+			return err
+		}
+	}
+	form.Close()
+
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := h.Session.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 302 {
+		return errors.New("uploadDidFail")
+	}
+	return nil
+}
+
+/*
+	Summary of the last upload of the "File:" page at fileurl, empty if the file has not been uploaded.
+	The file history lists the newest upload in its first row below the header, only this row counts: an older upload with the same hash does not mean the current file is the same.
+*/
+func (h *Handler) lastUploadSummary(fileurl string) string {
+	resp, err := h.Session.Get(fileurl)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return ""
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	history := fileHistoryRegEx.FindSubmatch(body)
+	if history == nil {
+		return ""
+	}
+	for _, row := range historyRowRegEx.FindAll(history[1], -1) {
+		if !historyCellRegEx.Match(row) { // Header
+			continue
+		}
+		if hash := uploadHashRegEx.FindSubmatch(row); hash != nil {
+			return "Hash:" + string(hash[1])
+		}
+		return "" // Current upload without hash, i.e. uploaded by hand
+	}
+	return ""
+}
+
+/*
+	Hidden fields of the edit form (i.e. wpEditToken, wpStarttime) needed to submit an edit.
+	wpPreview and wpDiff are left out, they would show the preview or the diff instead of saving.
+*/
+func (h *Handler) editTokens(editURL string) (map[string]string, error) {
+	resp, err := h.Session.Get(editURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("editFormNotFound")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make(map[string]string)
+	for _, input := range inputRegEx.FindAllString(string(body), -1) {
+		name := nameAttrRegEx.FindStringSubmatch(input)
+		if name == nil || name[1] == "wpPreview" || name[1] == "wpDiff" {
+			continue
+		}
+		value := ""
+		if match := valueAttrRegEx.FindStringSubmatch(input); match != nil {
+			value = html.UnescapeString(match[1])
+		}
+		payload[html.UnescapeString(name[1])] = value
+	}
+	if payload["wpEditToken"] == "" {
+		return nil, errors.New("noEditToken")
+	}
+	return payload, nil
+}
+
+var (
+	inputRegEx     = regexp.MustCompile(`(?s)<input\b[^>]*>`)
+	nameAttrRegEx  = regexp.MustCompile(`\bname="(.*?)"`)
+	valueAttrRegEx = regexp.MustCompile(`\bvalue="(.*?)"`)
+
+	fileHistoryRegEx = regexp.MustCompile(`(?s)<table class="wikitable filehistory">(.*?)</table>`)
+	historyRowRegEx  = regexp.MustCompile(`(?s)<tr\b.*?</tr>`)
+	historyCellRegEx = regexp.MustCompile(`<td\b`)
+	uploadHashRegEx  = regexp.MustCompile(`Hash:\s*([0-9a-f]{64})`)
+)
+
+/*
+	Downloads the file at fileurl (absolute, or relative to the wiki root) to the local path
+*/
+func (h *Handler) DownloadFile(fileurl, path string) error {
+	if fileurl[0] == '/' {
+		fileurl = h.BaseURL() + fileurl
+	}
+	resp, err := h.Session.Get(fileurl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.New("fileNotFound")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, resp.Body) // Stream to disk, media files can be quite big
+	return err
+}
+
+/*
+	Returns the url of the page a file gets uploaded to by Upload, relative to the wiki root (i.e. /Team:TU_Darmstadt/css/style).
+	Follows the naming of the API: the file extension is dropped, minified files get a "-min" suffix and index files (exactly index.*) are the root of the offset.
+	Like the API the root ends with a slash (/Team:TU_Darmstadt/), /Team:TU_Darmstadt is the redirect created by upload --redirect.
+*/
+func (h *Handler) PageURL(file, offset string) string {
+	return TeamPageURL(h.teamname, h.offset+offset, file)
+}
+
+/*
+	PageURL without a handler, offset is the full offset below the team namespace. Used where no connection to the iGEM Servers is needed (i.e. preview)
+*/
+func TeamPageURL(teamname, offset, file string) string {
+	name := strings.Split(filepath.Base(file), ".")
+	location := name[0]
+	if len(name) > 1 && strings.Contains(name[1], "min") {
+		location = location + "-min"
+	}
+	if name[0] == "index" {
+		location = ""
+	}
+
+	root := "/Team:" + teamname + "/"
+	if offset != "" {
+		root = root + offset + "/"
+	}
+	return root + location
+}
+
+/*
+	Returns the url of the "File:" page of a media file uploaded by UploadFile, the input for GetFileUrl
+*/
+func (h *Handler) FileURL(file string) string {
+	return h.BaseURL() + "/File:T--" + h.teamname + "--" + filepath.Base(file)
+}
+
+/*
+	Returns the root of the wiki of the current year, i.e. https://2021.igem.org
+*/
+func (h *Handler) BaseURL() string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+	return fmt.Sprintf("https://%d.igem.org", h.year)
+}
+
+/*
+	Returns the teamname the handler was created for
+*/
+func (h *Handler) Teamname() string {
+	return h.teamname
+}
+
+/*
+	Page urls returned by GetAllPages are escaped, i.e. /Team:TU_Darmstadt/Human%27s_Practices, PageURL returns them unescaped
+*/
+func UnescapePageURL(pageurl string) string {
+	if unescaped, err := url.PathUnescape(pageurl); err == nil {
+		return unescaped
+	}
+	return pageurl
 }
 
 /*
@@ -139,6 +577,6 @@ Internal Functions
 ------------------------------------------------------------------------------
 */
 
-func (h Handler) loggedIn() bool {
+func (h *Handler) loggedIn() bool {
 	return h.Session != nil
 }
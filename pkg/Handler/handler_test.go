@@ -0,0 +1,283 @@
+package gogemhandler
+
+import (
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTeamPageURL(t *testing.T) {
+	tests := []struct {
+		offset string
+		file   string
+		want   string
+	}{
+		{"", "index.html", "/Team:X/"},
+		{"test", "index.html", "/Team:X/test/"},
+		{"", "about.html", "/Team:X/about"},
+		{"test", "about.html", "/Team:X/test/about"},
+		{"css", "style.min.css", "/Team:X/css/style-min"},
+		{"", "reindex.html", "/Team:X/reindex"},
+		{"", "index-2.html", "/Team:X/index-2"},
+	}
+	for _, test := range tests {
+		if got := TeamPageURL("X", test.offset, test.file); got != test.want {
+			t.Errorf("TeamPageURL(%q, %q, %q) = %q, want %q", "X", test.offset, test.file, got, test.want)
+		}
+	}
+}
+
+func TestLastUploadSummary(t *testing.T) {
+	current := strings.Repeat("a", 64)
+	older := strings.Repeat("b", 64)
+	row := func(summary string) string {
+		return `<tr><td class="filehistory-selected">current</td><td>1 KB</td><td>` + summary + `</td></tr>`
+	}
+	header := `<tr><th>Date/Time</th><th>Dimensions</th><th>Comment</th></tr>`
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{"current upload", `<table class="wikitable filehistory">` + header + row("Hash:"+current) + row("Hash:"+older) + `</table>`, "Hash:" + current},
+		{"current upload without hash", `<table class="wikitable filehistory">` + header + row("new logo") + row("Hash:"+older) + `</table>`, ""},
+		{"no history", `<div class="noarticletext"></div>`, ""},
+	}
+	for _, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, test.page)
+		}))
+		h := &Handler{Session: srv.Client()}
+		if got := h.lastUploadSummary(srv.URL + "/File:T--X--logo.png"); got != test.want {
+			t.Errorf("%s: lastUploadSummary = %q, want %q", test.name, got, test.want)
+		}
+		srv.Close()
+	}
+}
+
+/*
+Minimal MediaWiki: edit forms, raw pages, Special:Upload and the file history, saved edits and uploads redirect like MediaWiki
+*/
+type fakeWiki struct {
+	pages    map[string]string // Title -> content
+	files    map[string]string // Destination -> content
+	history  map[string]string // Destination -> summary of the last upload
+	token    string
+	posts    int
+	requests int
+}
+
+func newFakeWiki() *fakeWiki {
+	return &fakeWiki{pages: make(map[string]string), files: make(map[string]string), history: make(map[string]string), token: `a+"\`}
+}
+
+func (wiki *fakeWiki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wiki.requests++
+	title := strings.TrimPrefix(r.URL.Path, "/")
+	form := `<form><input type="hidden" value="` + html.EscapeString(wiki.token) + `" name="wpEditToken"/><input name="wpPreview" value="Preview"/><input name="wpSummary" value=""/></form>`
+	switch {
+	case r.Method == "GET" && title == "Special:Upload":
+		io.WriteString(w, form)
+	case r.Method == "POST" && title == "Special:Upload":
+		wiki.posts++
+		file, _, err := r.FormFile("wpUploadFile")
+		if err != nil || r.FormValue("wpEditToken") != wiki.token {
+			io.WriteString(w, form) // MediaWiki shows the form again
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		dest := r.FormValue("wpDestFile")
+		wiki.files[dest] = string(content)
+		wiki.history[dest] = r.FormValue("wpUploadDescription")
+		http.Redirect(w, r, "/File:"+dest, http.StatusFound)
+	case strings.HasPrefix(title, "File:"):
+		summary, ok := wiki.history[strings.TrimPrefix(title, "File:")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, `<table class="wikitable filehistory"><tr><th>Comment</th></tr><tr><td>`+summary+`</td></tr></table>`)
+	case r.URL.Query().Get("action") == "raw":
+		content, ok := wiki.pages[title]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, content)
+	case r.URL.Query().Get("action") == "edit":
+		io.WriteString(w, form+`<script>"wgUserName":"Tester"</script>`)
+	case r.URL.Query().Get("action") == "submit":
+		wiki.posts++
+		if r.FormValue("wpEditToken") != wiki.token || r.FormValue("wpPreview") != "" || !strings.HasPrefix(r.FormValue("wpSummary"), "Hash:") {
+			io.WriteString(w, form)
+			return
+		}
+		wiki.pages[title] = r.FormValue("wpTextbox1")
+		http.Redirect(w, r, "/"+title, http.StatusFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+/*
+Handler logged in to the wiki, the client does not follow redirects like the client of the API
+*/
+func testHandler(t *testing.T, wiki *fakeWiki) *Handler {
+	srv := httptest.NewServer(wiki)
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Handler{Session: client, teamname: "X", baseURL: srv.URL, alreadyUploaded: make(map[string]bool)}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestUpload(t *testing.T) {
+	wiki := newFakeWiki()
+	h := testHandler(t, wiki)
+	index := writeFile(t, "index.html", "<p>Home</p>")
+	style := writeFile(t, "style.min.css", "p{}")
+
+	tests := []struct {
+		name    string
+		file    string
+		offset  string
+		force   bool
+		wantURL string
+		wantErr string
+	}{
+		{"new page", index, "", false, h.BaseURL() + "/Team:X/", ""},
+		{"same content", index, "", false, "", "fileAlreadyUploaded"},
+		{"forced", index, "", true, h.BaseURL() + "/Team:X/", ""},
+		{"stylesheet", style, "css", false, h.BaseURL() + "/Team:X/css/style-min", ""},
+	}
+	for _, test := range tests {
+		url, err := h.Upload(test.file, test.offset, test.force)
+		if url != test.wantURL || errorString(err) != test.wantErr {
+			t.Errorf("%s: Upload = %q, %v, want %q, %q", test.name, url, err, test.wantURL, test.wantErr)
+		}
+	}
+	if wiki.pages["Team:X/"] != "<p>Home</p>" || wiki.pages["Team:X/css/style-min"] != "p{}" {
+		t.Errorf("pages on the wiki: %v", wiki.pages)
+	}
+	if wiki.posts != 3 {
+		t.Errorf("%d edits submitted, want 3", wiki.posts)
+	}
+}
+
+func TestRedirectAndDeletePage(t *testing.T) {
+	wiki := newFakeWiki()
+	h := testHandler(t, wiki)
+
+	if err := h.Redirect("", "/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Redirect("About", "about"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DeletePage("/Team:X/about"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Team:X":       "#REDIRECT[[Team:X/]]",
+		"Team:X/About": "#REDIRECT[[Team:X/about]]",
+		"Team:X/about": `<div class="purged-page-empty"></div>`,
+	}
+	for title, content := range want {
+		if wiki.pages[title] != content {
+			t.Errorf("%s = %q, want %q", title, wiki.pages[title], content)
+		}
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	wiki := newFakeWiki()
+	h := testHandler(t, wiki)
+	logo := writeFile(t, "logo.png", "PNG")
+
+	url, err := h.UploadFile(logo, false)
+	if err != nil || url != h.BaseURL()+"/File:T--X--logo.png" {
+		t.Fatalf("UploadFile = %q, %v", url, err)
+	}
+	if wiki.files["T--X--logo.png"] != "PNG" || !strings.HasPrefix(wiki.history["T--X--logo.png"], "Hash:") {
+		t.Errorf("files on the wiki: %v %v", wiki.files, wiki.history)
+	}
+	if _, err := h.UploadFile(logo, false); errorString(err) != "alreadyUploadedInThisSession" {
+		t.Errorf("second upload in the session: %v", err)
+	}
+
+	next := testHandler(t, wiki) // Next run
+	next.baseURL = h.baseURL
+	if _, err := next.UploadFile(logo, false); errorString(err) != "fileAlreadyUploaded" {
+		t.Errorf("upload of an unchanged file: %v", err)
+	}
+	next.alreadyUploaded = make(map[string]bool)
+	if err := ioutil.WriteFile(logo, []byte("PNG2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.UploadFile(logo, false); err != nil || wiki.files["T--X--logo.png"] != "PNG2" {
+		t.Errorf("upload of a changed file: %v, %q", err, wiki.files["T--X--logo.png"])
+	}
+	if wiki.posts != 2 {
+		t.Errorf("%d uploads submitted, want 2", wiki.posts)
+	}
+}
+
+func TestEditPageErrors(t *testing.T) {
+	wiki := newFakeWiki()
+	h := testHandler(t, wiki)
+
+	if _, err := (&Handler{}).EditPage("Template:X", "x"); errorString(err) != "notLoggedIn" {
+		t.Errorf("without session: %v", err)
+	}
+	wiki.token = ""
+	if _, err := h.EditPage("Template:X", "x"); errorString(err) != "noEditToken" {
+		t.Errorf("without edit token: %v", err)
+	}
+	if len(wiki.pages) != 0 {
+		t.Errorf("pages on the wiki: %v", wiki.pages)
+	}
+}
+
+func TestMissingPagesAreFinal(t *testing.T) {
+	wiki := newFakeWiki()
+	h := testHandler(t, wiki)
+
+	if _, err := h.GetRawPage("/Team:X/new"); errorString(err) != "pageNotFound" {
+		t.Errorf("GetRawPage: %v", err)
+	}
+	if _, err := h.GetFileUrl(h.FileURL("new.png")); errorString(err) != "fileNotFound" {
+		t.Errorf("GetFileUrl: %v", err)
+	}
+	if wiki.requests != 2 { // No check of the session
+		t.Errorf("%d requests, want 2", wiki.requests)
+	}
+
+	wiki.requests = 0
+	if _, err := h.EditPage("Team:X/new", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if wiki.requests != 3 { // Raw page, edit form and submit
+		t.Errorf("%d requests for a new page, want 3", wiki.requests)
+	}
+}
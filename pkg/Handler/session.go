@@ -0,0 +1,264 @@
+package gogemhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Session stored between commands, gets written to the users cache directory and is only readable by the user.
+There is one session per user and wiki, so the sessions for the wikis of different years do not replace each other.
+*/
+type sessionCache struct {
+	Username string                     `json:"username"`
+	Expires  time.Time                  `json:"expires"`
+	Lifetime time.Duration              `json:"lifetime"` // Used again if the session has to be renewed
+	Cookies  map[string][]sessionCookie `json:"cookies"`  // Cookies of the cookie jar by the url they are sent to
+}
+
+type sessionCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+/*
+Stores the cookies of the session for lifetime, so following commands can reuse the session instead of logging in again.
+The handler will not log out on Close anymore, this has to be done by Logout.
+*/
+func (h *Handler) Persist(lifetime time.Duration) error {
+	cache := sessionCache{
+		Username: h.username,
+		Expires:  time.Now().Add(lifetime),
+		Lifetime: lifetime,
+		Cookies:  make(map[string][]sessionCookie),
+	}
+
+	for _, link := range h.cookieURLs() {
+		u, err := url.Parse(link)
+		if err != nil {
+			return err
+		}
+		for _, cookie := range h.Session.Jar.Cookies(u) {
+			cache.Cookies[link] = append(cache.Cookies[link], sessionCookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+
+	path, err := sessionFile(h.username, h.BaseURL())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil { // WriteFile keeps the permissions of an existing file
+		return err
+	}
+
+	h.persistent = true
+	h.lifetime = lifetime
+	return nil
+}
+
+/*
+Returns when the stored session of the user for the wiki at baseURL expires, and false if there is none
+*/
+func SessionExpiry(username, baseURL string) (time.Time, bool) {
+	cache, err := loadSession(username, baseURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cache.Expires, true
+}
+
+/*
+Removes the stored session of the user for the wiki at baseURL, without logging out
+*/
+func ForgetSession(username, baseURL string) error {
+	path, err := sessionFile(username, baseURL)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+/*
+------------------------------------------------------------------------------
+Internal Functions
+------------------------------------------------------------------------------
+*/
+
+/*
+Errors of the API that are final, retrying with a new session would not change the outcome.
+Pages and files that do not exist are expected (i.e. new pages), they do not need a check of the session.
+*/
+var finalErrors = map[string]bool{
+	"notLoggedIn":                  true,
+	"alreadyUploadedInThisSession": true,
+	"fileAlreadyUploaded":          true,
+	"pageNotFound":                 true,
+	"fileNotFound":                 true,
+}
+
+/*
+Runs the call, if it fails and the session turns out to be expired, logs in again with the stored credentials and retries the call once.
+Long uploads outlive the session on the iGEM Servers, this way they do not get lost halfway.
+Calls only use the unwrapped requests (i.e. getRawPage), so the retries are not nested.
+*/
+func (h *Handler) retry(call func() error) error {
+	err := call()
+	if err == nil || finalErrors[err.Error()] || !h.sessionExpired() {
+		return err
+	}
+
+	println("Session expired, logging in again as " + h.username + "...")
+	if err := h.relogin(); err != nil {
+		return err
+	}
+	println("Logged in again, retrying")
+	return call()
+}
+
+/*
+Checks if the session is still logged in, by requesting the edit page of the team root.
+Logged out users get redirected to the login, or see a page denying the permission to edit.
+*/
+func (h *Handler) sessionExpired() bool {
+	resp, err := h.Session.Get(h.BaseURL() + "/Team:" + h.teamname + "?action=edit")
+	if err != nil {
+		return false // Not a problem of the session
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 { // The session client does not follow redirects
+		location := resp.Header.Get("Location")
+		return strings.Contains(location, "Login") || strings.Contains(location, "login")
+	}
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return true
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false
+	}
+	for _, marker := range loggedOutMarkers {
+		if strings.Contains(string(body), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+var loggedOutMarkers = []string{
+	`"wgUserName":null`, // MediaWiki exports the name of the logged in user to JavaScript, anonymous users have none
+	`permissions-errors`,
+	`Login required`,
+	`You do not have permission to edit this page`,
+}
+
+/*
+Replaces the session with a new login, a stored session gets updated as well
+*/
+func (h *Handler) relogin() error {
+	if h.password == nil {
+		return errors.New("sessionExpired")
+	}
+	password, err := h.password()
+	if err != nil {
+		return err
+	}
+	session, err := h.Login(h.username, password)
+	if err != nil {
+		return err
+	}
+	h.Session = session
+
+	if h.persistent {
+		return h.Persist(h.lifetime)
+	}
+	return nil
+}
+
+/*
+Path of the stored session, keyed by the user and the wiki (i.e. session-Jackd4w@2021.igem.org.json)
+*/
+func sessionFile(username, baseURL string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	wiki := strings.TrimSuffix(u.Host+u.Path, "/")
+	return filepath.Join(dir, "GoGEM", "session-"+url.QueryEscape(username)+"@"+url.QueryEscape(wiki)+".json"), nil
+}
+
+func loadSession(username, baseURL string) (*sessionCache, error) {
+	path, err := sessionFile(username, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cache := new(sessionCache)
+	if err := json.Unmarshal(content, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+/*
+Creates a client with the stored cookies, configured like the client created by the API on login
+*/
+func (h *Handler) restoreSession(cache *sessionCache) *http.Client {
+	jar, _ := cookiejar.New(nil) // Never fails without options
+
+	for link, cookies := range cache.Cookies {
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		var restored []*http.Cookie
+		for _, cookie := range cookies {
+			restored = append(restored, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		jar.SetCookies(u, restored)
+	}
+
+	return &http.Client{
+		Jar:     jar,
+		Timeout: time.Duration(h.timeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { // Redirects are not allowed, because this tends to mess with the cookie jar
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+/*
+The login is shared between igem.org and the wikis of all years, the cookies are collected from the login server and the wiki
+*/
+func (h *Handler) cookieURLs() []string {
+	return []string{h.loginURL, h.logoutURL, h.BaseURL() + "/"}
+}
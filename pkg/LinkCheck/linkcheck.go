@@ -0,0 +1,251 @@
+package GoGEMlinkcheck
+
+import (
+	"crypto/tls"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	wp "github.com/Jackd4w/GoGEM/pkg/GoStatic"
+	"github.com/gocolly/colly"
+)
+
+/*
+	Crawls the deployed Wiki of a team and reports the problems found on every page:
+	pages and references that return 404, empty pages, links leaving igem.org, references to the original WordPress domain and mixed content.
+*/
+
+/*
+	Problem found on a page, Kind is a short machine readable identifier:
+	notFound, empty, brokenLink, brokenResource, external, wordpress and mixedContent
+*/
+type Finding struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"` // Referenced url the finding is about
+}
+
+type PageReport struct {
+	URL      string    `json:"url"`
+	Status   int       `json:"status"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+type reference struct {
+	url      string
+	resource bool // Loaded by the browser (i.e. images, scripts, stylesheets), not only linked
+}
+
+// Elements of the page content that reference other urls, and the attribute holding the url
+var referenceSelectors = []struct {
+	selector  string
+	attribute string
+	resource  bool
+}{
+	{"a[href]", "href", false},
+	{"link[href]", "href", true},
+	{"script[src]", "src", true},
+	{"img[src]", "src", true},
+	{"video[src]", "src", true},
+	{"audio[src]", "src", true},
+	{"source[src]", "src", true},
+	{"iframe[src]", "src", true},
+}
+
+/*
+	Crawls all pages of the team below wiki (i.e. https://2021.igem.org) through the links in their content and checks every reference.
+	wordpress is the url of the original WordPress Page, references to it are reported. It can be empty.
+	timeout is used for the requests that check the referenced urls.
+	Returns one report per crawled page, sorted by url.
+*/
+func Check(wiki, team, wordpress string, insecure bool, timeout int) ([]PageReport, error) {
+	base, err := neturl.Parse(wiki)
+	if err != nil {
+		return nil, err
+	}
+	teamPath := "/Team:" + team
+	wpHost := ""
+	if wordpress != "" {
+		if u, err := neturl.Parse(wordpress); err == nil {
+			wpHost = u.Host
+		}
+	}
+
+	var mutex sync.Mutex
+	reports := make(map[string]*PageReport)
+	references := make(map[string][]reference) // Page -> references in its content
+	status := make(map[string]int)             // Status of every crawled url
+
+	c := wp.NewCollector(base.Host, insecure)
+	c.ParseHTTPErrorResponse = true // Needed to see the 404s in OnResponse
+
+	for _, s := range referenceSelectors {
+		s := s
+		c.OnHTML("#mw-content-text "+s.selector, func(e *colly.HTMLElement) { // Only the content of the team, not the iGEM navigation
+			link := strings.TrimSpace(e.Attr(s.attribute))
+			if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "javascript:") || strings.HasPrefix(link, "data:") {
+				return
+			}
+			absolute := e.Request.AbsoluteURL(link)
+			page := e.Request.URL.String()
+
+			mutex.Lock()
+			references[page] = append(references[page], reference{url: absolute, resource: s.resource})
+			mutex.Unlock()
+
+			if !s.resource && isTeamPage(absolute, base.Host, teamPath) {
+				e.Request.Visit(absolute)
+			}
+		})
+	}
+
+	c.OnResponse(func(r *colly.Response) {
+		page := r.Request.URL.String()
+		report := &PageReport{URL: page, Status: r.StatusCode}
+		body := string(r.Body)
+
+		if r.StatusCode == 404 {
+			report.add("notFound", "Page not found", "")
+		} else if strings.Contains(body, `cnoarticletext`) || strings.Contains(body, `purged-page-empty`) || strings.Contains(body, `(page does not exist)`) {
+			report.add("empty", "Page is empty", "")
+		}
+		if wpHost != "" {
+			for _, link := range wordpressLinks(body, wpHost) {
+				report.add("wordpress", "Reference to the WordPress Page "+link, link)
+			}
+		}
+
+		mutex.Lock()
+		reports[page] = report
+		status[page] = r.StatusCode
+		mutex.Unlock()
+	})
+
+	if err := c.Visit(wiki + teamPath); err != nil {
+		return nil, err
+	}
+	c.Wait()
+
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	if insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	var result []PageReport
+	for page, report := range reports {
+		seen := make(map[string]bool)
+		for _, ref := range references[page] {
+			if seen[ref.url] {
+				continue
+			}
+			seen[ref.url] = true
+			checkReference(report, ref, base, wpHost, client, status)
+		}
+		result = append(result, *report)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result, nil
+}
+
+/*
+	Checks one reference of the page, references to igem.org are requested once (unless they have already been crawled)
+*/
+func checkReference(report *PageReport, ref reference, base *neturl.URL, wpHost string, client *http.Client, status map[string]int) {
+	u, err := neturl.Parse(ref.url)
+	if err != nil {
+		return
+	}
+
+	if wpHost != "" && u.Host == wpHost { // Already reported when the page was crawled
+		return
+	}
+	if host := strings.ToLower(u.Hostname()); host != "igem.org" && !strings.HasSuffix(host, ".igem.org") { // Not notigem.org
+		if ref.resource {
+			report.add("external", "External resource "+ref.url+" will be blocked by iGEM", ref.url)
+		} else {
+			report.add("external", "Link leaves igem.org: "+ref.url, ref.url)
+		}
+		return
+	}
+	if ref.resource && base.Scheme == "https" && u.Scheme == "http" {
+		report.add("mixedContent", "Resource is loaded over http: "+ref.url, ref.url)
+	}
+
+	u.Fragment = ""
+	target := u.String()
+	code, ok := status[target]
+	if !ok {
+		code = 0
+		if resp, err := client.Head(target); err == nil {
+			code = resp.StatusCode
+			resp.Body.Close()
+		}
+		status[target] = code
+	}
+	if code == 404 || code == 0 {
+		if ref.resource {
+			report.add("brokenResource", "Resource not found: "+target, target)
+		} else {
+			report.add("brokenLink", "Broken link to "+target, target)
+		}
+	}
+}
+
+/*
+	Pages of the team, without queries like ?action=edit
+*/
+func isTeamPage(link, host, teamPath string) bool {
+	u, err := neturl.Parse(link)
+	if err != nil || u.Host != host || u.RawQuery != "" {
+		return false
+	}
+	return u.Path == teamPath || strings.HasPrefix(u.Path, teamPath+"/")
+}
+
+/*
+	All urls in the body pointing to the WordPress host, also the ones hidden in inline styles or scripts
+*/
+func wordpressLinks(body, wpHost string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, prefix := range []string{"http://" + wpHost, "https://" + wpHost, "//" + wpHost} {
+		for rest := body; ; {
+			index := strings.Index(rest, prefix)
+			if index < 0 {
+				break
+			}
+			end := strings.IndexAny(rest[index:], "\"' )<>")
+			if end < 0 {
+				end = len(rest) - index
+			}
+			link := rest[index : index+end]
+			if !seen[link] && (prefix != "//"+wpHost || index == 0 || rest[index-1] != ':') { // Protocol relative links, without the ones found above
+				seen[link] = true
+				links = append(links, link)
+			}
+			rest = rest[index+end:]
+		}
+	}
+	return links
+}
+
+func (r *PageReport) add(kind, message, url string) {
+	r.Findings = append(r.Findings, Finding{Kind: kind, Message: message, URL: url})
+}
+
+/*
+	Number of findings in all reports
+*/
+func Count(reports []PageReport) int {
+	count := 0
+	for _, r := range reports {
+		count += len(r.Findings)
+	}
+	return count
+}
@@ -0,0 +1,108 @@
+package GoGEMoutput
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+	Structured output of all commands.
+	In text mode only the message of an event is printed, like every other status message of GoGEM.
+	In json mode all events are collected and written to stdout as one array when the command is done, in ndjson mode every event is written to stdout as one line as soon as it happens.
+	Status messages keep going to stderr in every mode, so stdout can be piped into other tools.
+*/
+
+const (
+	Text   = "text"
+	JSON   = "json"
+	NDJSON = "ndjson"
+)
+
+// Event types
+const (
+	FileUploaded = "fileUploaded"
+	PageUploaded = "pageUploaded"
+	PageFound    = "pageFound"
+	PageDeleted  = "pageDeleted"
+	Criterion    = "criterion"
+	PageChecked  = "pageChecked"
+	Summary      = "summary"
+	Error        = "error"
+)
+
+type Event struct {
+	Type     string      `json:"type"`
+	Time     time.Time   `json:"time"`
+	Message  string      `json:"message,omitempty"`  // Human readable message, the only thing printed in text mode
+	Source   string      `json:"source,omitempty"`   // Local file the event is about
+	URL      string      `json:"url,omitempty"`      // URL on the iGEM Servers the event is about
+	Category string      `json:"category,omitempty"` // Category of errors, i.e. login, fileUpload, pageUpload
+	Data     interface{} `json:"data,omitempty"`     // Command specific payload
+}
+
+var format = Text
+var events []Event
+var writer io.Writer = os.Stdout
+
+/*
+Sets the output format, one of text, json or ndjson
+*/
+func SetFormat(f string) error {
+	if f != Text && f != JSON && f != NDJSON {
+		return errors.New("unknown output format " + f + ", use text, json or ndjson")
+	}
+	format = f
+	return nil
+}
+
+/*
+Returns true if the output is machine readable
+*/
+func Structured() bool {
+	return format != Text
+}
+
+/*
+Emits the event in the configured format
+*/
+func Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	switch format {
+	case JSON:
+		events = append(events, e)
+	case NDJSON:
+		line, _ := json.Marshal(e)
+		writer.Write(append(line, '\n'))
+	default:
+		if e.Message != "" {
+			println(e.Message)
+		}
+	}
+}
+
+/*
+Shortcut for error events
+*/
+func EmitError(category, message string) {
+	Emit(Event{Type: Error, Category: category, Message: message})
+}
+
+/*
+Writes all collected events in json mode, has to be called once after the command is done
+*/
+func Flush() {
+	if format != JSON {
+		return
+	}
+	if events == nil {
+		events = []Event{}
+	}
+	content, _ := json.MarshalIndent(events, "", "  ")
+	writer.Write(append(content, '\n'))
+	events = nil
+}
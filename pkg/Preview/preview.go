@@ -0,0 +1,311 @@
+package GoGEMpreview
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	fh "github.com/Jackd4w/GoGEM/pkg/FileHandling"
+)
+
+/*
+	Serves a prepared project on localhost the way the iGEM Wiki would show it:
+	pages are wrapped in an approximation of the iGEM navigation and footer, template calls are resolved
+	and ?action=raw urls are answered with the local stylesheets and scripts.
+	The browser reloads the page whenever a file of the project changes.
+*/
+
+type Options struct {
+	Root      string // Project directory
+	Teamname  string
+	Offset    string
+	Templates fh.Templates           // Team templates of the pages, the call of the team template that replaced the DOCTYPE is removed
+	Addr      string                 // i.e. localhost:8080
+	Header    string                 // HTML in front of every page, approximating the iGEM navigation
+	Footer    string                 // HTML after every page
+	Prepare   func(dir string) error // Optional, prepares a copy of Root after every change, so Root can be the unprepared project
+}
+
+// Approximation of the iGEM 2021 page shell: the black menu bar on top and the footer below the content
+const DefaultHeader = `<div id="top_menu_under" style="height:18px"></div>
+<div id="top_menu_14" style="position:fixed;top:0;left:0;right:0;height:18px;z-index:1000;background:#000;color:#fff;font:11px/18px Arial,sans-serif;padding:0 10px">iGEM &middot; Team:%s &middot; GoGEM Preview</div>`
+
+const DefaultFooter = `<div id="footer-box" style="clear:both;background:#fff;color:#000;font:11px Arial,sans-serif;padding:10px;border-top:1px solid #ccc">iGEM Foundation &middot; This page is a preview and has not been uploaded</div>`
+
+const reloadPath = "/__gogem/reload"
+
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function () { location.reload() }</script>`
+
+var templateCallRegEx = regexp.MustCompile(`\{\{([^{}|]+)\}\}`)
+
+type server struct {
+	Options
+	mutex   sync.RWMutex
+	dir     string            // Directory that is served, Root or the prepared copy
+	pages   map[string]string // Page url -> file
+	clients map[chan bool]bool
+}
+
+/*
+	Serves the project until the server fails or is stopped
+*/
+func Serve(opts Options) error {
+	s := &server{Options: opts, clients: make(map[chan bool]bool)}
+	if s.Header == "" {
+		s.Header = fmt.Sprintf(DefaultHeader, s.Teamname)
+	}
+	if s.Footer == "" {
+		s.Footer = DefaultFooter
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	go s.watch()
+
+	if s.Prepare != nil { // Remove the prepared copy when the preview is stopped with Ctrl+C
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			s.mutex.Lock()
+			os.RemoveAll(s.dir)
+			os.Exit(0)
+		}()
+	}
+
+	println(fmt.Sprintf("Preview of %s on http://%s/Team:%s", s.Root, s.Addr, s.Teamname))
+	return http.ListenAndServe(s.Addr, s)
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The server is not behind a ServeMux, which would clean the path: /Team:X/../../ must not leave the project
+	cleaned := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	r.URL.Path = cleaned
+
+	if r.URL.Path == reloadPath {
+		s.events(w, r)
+		return
+	}
+	teamPath := "/Team:" + s.Teamname
+	if !strings.HasPrefix(r.URL.Path, teamPath) {
+		http.Redirect(w, r, teamPath+"/"+s.Offset, http.StatusFound)
+		return
+	}
+	if r.URL.Path == teamPath { // Like the redirect created by upload --redirect, relative links of the index page need the trailing slash
+		http.Redirect(w, r, teamPath+"/", http.StatusFound)
+		return
+	}
+
+	s.mutex.RLock()
+	file, ok := s.pages[r.URL.Path]
+	if !ok { // The roots of team and offset end with a slash, the other pages do not
+		file, ok = s.pages[strings.TrimSuffix(r.URL.Path, "/")]
+	}
+	if !ok {
+		file, ok = s.pages[r.URL.Path+"/"]
+	}
+	dir := s.dir
+	s.mutex.RUnlock()
+
+	if ok {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("action") == "raw" || !strings.Contains(filepath.Base(file), ".htm") {
+			ctype := r.URL.Query().Get("ctype")
+			if ctype == "" {
+				ctype = "text/x-wiki" // MediaWiki answers raw requests without ctype as wikitext
+			}
+			w.Header().Set("Content-Type", ctype+"; charset=UTF-8")
+			w.Write(content)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		io.WriteString(w, s.render(r.URL.Path, file, string(content)))
+		return
+	}
+
+	// Everything else below the team is served from the project, i.e. ./assets/ that have not been replaced with uploaded files
+	rel := strings.TrimPrefix(r.URL.Path, teamPath)
+	if s.Offset != "" {
+		rel = strings.TrimPrefix(rel, "/"+s.Offset)
+	}
+	if file, err := http.Dir(dir).Open(rel); err == nil { // http.Dir stays inside of dir
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && !info.IsDir() {
+			http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	io.WriteString(w, s.render(r.URL.Path, "", `<div class="noarticletext"><p>There is currently no text in this page.</p></div>`))
+}
+
+/*
+	Wraps the page in the shell and resolves its template calls
+*/
+func (s *server) render(pageurl, file, content string) string {
+	teamTemplate := s.Templates.PageTemplate(file)
+	content = templateCallRegEx.ReplaceAllStringFunc(content, func(call string) string {
+		name := strings.TrimSpace(templateCallRegEx.FindStringSubmatch(call)[1])
+		if name == teamTemplate {
+			return "" // The team template only adds the iGEM parts, which are approximated by the shell
+		}
+		name = strings.TrimPrefix(name, "Template:")
+		name = strings.TrimPrefix(name, s.Teamname+"/")
+		s.mutex.RLock()
+		template, err := ioutil.ReadFile(filepath.Join(s.dir, "templates", name+".html"))
+		s.mutex.RUnlock()
+		if err != nil {
+			return call // Unknown templates are shown like MediaWiki does for missing templates
+		}
+		return string(template)
+	})
+
+	return "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"UTF-8\">\n<title>" + strings.TrimPrefix(pageurl, "/") + " - iGEM</title>\n</head>\n<body>\n" +
+		s.Header + "\n<div id=\"content\"><div id=\"mw-content-text\">\n" + content + "\n</div></div>\n" + s.Footer + "\n" + reloadScript + "\n</body>\n</html>\n"
+}
+
+/*
+	Server sent events, a message tells the browser to reload
+*/
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	changed := make(chan bool, 1)
+	s.mutex.Lock()
+	s.clients[changed] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, changed)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-changed:
+			io.WriteString(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+/*
+	Polls the modification times of the project, there is no portable file watcher in the standard library
+*/
+func (s *server) watch() {
+	last := s.snapshot()
+	for range time.Tick(500 * time.Millisecond) {
+		current := s.snapshot()
+		if current == last {
+			continue
+		}
+		last = current
+		println("Files changed, reloading...")
+		if err := s.reload(); err != nil {
+			println(err.Error())
+			continue
+		}
+		s.mutex.RLock()
+		for client := range s.clients {
+			select {
+			case client <- true:
+			default: // Reload already pending
+			}
+		}
+		s.mutex.RUnlock()
+	}
+}
+
+func (s *server) snapshot() string {
+	snapshot := ""
+	filepath.Walk(s.Root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			snapshot += fmt.Sprintf("%s %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+		}
+		return nil
+	})
+	return snapshot
+}
+
+/*
+	Prepares a fresh copy of the project if necessary and collects the pages
+*/
+func (s *server) reload() error {
+	dir := s.Root
+	if s.Prepare != nil {
+		var err error
+		if dir, err = ioutil.TempDir("", "gogem-preview"); err != nil {
+			return err
+		}
+		if err := copyDir(s.Root, dir); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		if err := s.Prepare(dir); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+
+	pages, err := fh.TeamPagePaths(dir, s.Teamname, s.Offset)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	old := s.dir
+	s.dir = dir
+	s.pages = pages
+	s.mutex.Unlock()
+
+	if s.Prepare != nil && old != "" {
+		os.RemoveAll(old)
+	}
+	return nil
+}
+
+func copyDir(source, target string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(target, rel), 0755)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(target, rel), content, 0644)
+	})
+}
@@ -0,0 +1,60 @@
+package GoGEMpreview
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPStaysInProject(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	if err := os.MkdirAll(filepath.Join(project, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(root, "secret.txt"):            "secret",
+		filepath.Join(project, "index.html"):         "<p>Home</p>",
+		filepath.Join(project, "assets", "logo.png"): "PNG",
+	}
+	for file, content := range files {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &server{Options: Options{Root: project, Teamname: "X"}, clients: make(map[chan bool]bool)}
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/Team:X/", http.StatusOK, "<p>Home</p>"},
+		{"/Team:X/assets/logo.png", http.StatusOK, "PNG"},
+		{"/Team:X/../secret.txt", http.StatusFound, ""},
+		{"/Team:X/../../secret.txt", http.StatusFound, ""},
+		{"/Team:X/assets/../../secret.txt", http.StatusFound, ""},
+		{"/Team:X/..%2Fsecret.txt", http.StatusFound, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://localhost"+test.path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.path, rec.Code, test.status)
+		}
+		if strings.Contains(rec.Body.String(), "secret") && !strings.Contains(test.body, "secret") {
+			t.Errorf("%s: served a file outside of the project", test.path)
+		}
+		if test.body != "" && !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("%s: body %q does not contain %q", test.path, rec.Body.String(), test.body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package GoGEMprofile
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	cc "github.com/Jackd4w/GoGEM/pkg/checkCriteria"
+)
+
+/*
+	Everything that changes between the competition years: the wiki, the login endpoints, the pages judged for medals and awards, the team templates and the fonts hosted on the wiki.
+	Profiles for the supported years are bundled with GoGEM, users can override them or add profiles for new years.
+*/
+type Profile struct {
+	Year          int
+	BaseURL       string                     // Root of the wiki, i.e. https://2021.igem.org
+	LoginURL      string                     // Shared by all years
+	LogoutURL     string                     // Shared by all years
+	PrefixURL     string                     // Special:PrefixIndex of the wiki
+	MathJaxURL    string                     // MathJax hosted by iGEM, replaces the ADD_MATHJAX placeholder
+	Templates     map[string]string          // Team templates wrapping the pages by page group (file name pattern, i.e. blog-*), %s is replaced with the teamname. "Default" is used for all other pages
+	RequiredPages map[string]string          // Pages judged for the medals: criterion -> page relative to the team page
+	AwardPages    map[string]string          // Pages judged for the special awards: award -> page relative to the team page
+	Order         []string                   // Order of the criteria in reports, entries without a page are headlines
+	Criteria      map[string]cc.Requirements // Content requirements of the criteria pages, see checkCriteria
+	Fonts         map[string]string          // Fonts uploaded to the wiki of the year: font family -> url() replacing it in svg files
+}
+
+//go:embed profiles/*.json
+var bundled embed.FS
+
+/*
+	Loads the profile of the year. The bundled profile is overridden by GoGEM/profiles/[year].json in the config directory of the user and then by the files, in that order.
+	Every override only has to contain the fields it changes: lists and values are replaced, maps are merged entry by entry.
+	Returns noProfile if there is neither a bundled nor a user profile for the year.
+*/
+func Load(year int, files ...string) (*Profile, error) {
+	profile := &Profile{Year: year}
+	found := false
+
+	content, err := bundled.ReadFile("profiles/" + strconv.Itoa(year) + ".json")
+	if err == nil {
+		if err := json.Unmarshal(content, profile); err != nil {
+			return nil, err
+		}
+		found = true
+	}
+
+	if dir, err := UserDir(); err == nil {
+		user := filepath.Join(dir, strconv.Itoa(year)+".json")
+		if _, err := os.Stat(user); err == nil {
+			files = append([]string{user}, files...)
+		}
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, profile); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, errors.New("noProfile")
+	}
+	if profile.Year != year {
+		return nil, fmt.Errorf("profile is for %d, not for %d", profile.Year, year)
+	}
+	if profile.BaseURL == "" {
+		profile.BaseURL = fmt.Sprintf("https://%d.igem.org", year)
+	}
+	return profile, nil
+}
+
+/*
+	Returns the years with a bundled profile
+*/
+func Bundled() []int {
+	var years []int
+	entries, _ := fs.ReadDir(bundled, "profiles")
+	for _, entry := range entries {
+		if year, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json")); err == nil {
+			years = append(years, year)
+		}
+	}
+	sort.Ints(years)
+	return years
+}
+
+/*
+	Directory for the profiles of the user, GoGEM/profiles in the config directory
+*/
+func UserDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "GoGEM", "profiles"), nil
+}
+
+/*
+	All criteria pages in the format of the URLs in the config: criterion -> page, headlines of the order map to "#"
+*/
+func (p *Profile) URLs() map[string]string {
+	urls := make(map[string]string)
+	for _, criterion := range p.Order {
+		urls[criterion] = "#"
+	}
+	for criterion, page := range p.RequiredPages {
+		urls[criterion] = page
+	}
+	for criterion, page := range p.AwardPages {
+		urls[criterion] = page
+	}
+	return urls
+}
+
+/*
+	Returns the name of the team template wrapping the page, i.e. TU_Darmstadt for index.html.
+	The keys of Templates are page groups: patterns of file names as in filepath.Match (i.e. blog-*), the longest matching pattern wins.
+	Pages that are in no group use the Default template, without Default the template is named like the team as the standard template of iGEM.
+*/
+func (p *Profile) Template(page, teamname string) string {
+	page = filepath.Base(page)
+	var groups []string
+	for group := range p.Templates {
+		if group != "Default" {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) > len(groups[j])
+		}
+		return groups[i] < groups[j]
+	})
+
+	template, ok := p.Templates["Default"]
+	for _, group := range groups {
+		if matched, _ := filepath.Match(group, page); matched {
+			template, ok = p.Templates[group], true
+			break
+		}
+	}
+	if !ok {
+		template = "%s"
+	}
+	if strings.Contains(template, "%s") {
+		return fmt.Sprintf(template, teamname)
+	}
+	return template
+}
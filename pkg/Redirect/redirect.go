@@ -39,6 +39,9 @@ func CreateUppercaseRedirects(urls map[string]string, h *h.Handler) {
 	h.Redirect("", "/") // Redirects from https...igem.org/Team:teamname to https...igem.org/Team:teamname/
 
 	for _, url := range urls {
+		if url == "#" { // Headline, not a page
+			continue
+		}
 		h.Redirect(url, strings.ToLower(url))
 	}
 }
@@ -0,0 +1,194 @@
+package GoGEMreferences
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+/*
+	Finds the references of an HTML document to other pages and files, driven by an explicit table of elements and attributes.
+	Used by the crawler to find everything that has to be downloaded, and by the upload to find the media files that have to be uploaded.
+*/
+
+/*
+	Reference found in a document. Raw is the value as it is written in the document (i.e. with &amp;), URL the value the browser uses.
+	Resource references are loaded by the browser or downloaded (i.e. images, posters, favicons, files of download links), the others only link to pages.
+*/
+type Reference struct {
+	URL       string
+	Raw       string
+	Element   string
+	Attribute string
+	Resource  bool
+}
+
+/*
+	Attribute of an element that references a url, "*" matches every element.
+	When is an optional condition on the other attributes of the element, i.e. the rel of a link.
+*/
+type rule struct {
+	element   string
+	attribute string
+	resource  bool
+	css       bool // The attribute contains css, the urls are in url()
+	when      func(attributes map[string]string) bool
+}
+
+// Values of rel that make the browser load the linked file
+var resourceRels = []string{"stylesheet", "icon", "shortcut icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon", "preload", "manifest"}
+
+var rules = []rule{
+	{element: "a", attribute: "href", when: not(hasAttribute("download"))},
+	{element: "a", attribute: "href", resource: true, when: hasAttribute("download")},
+	{element: "area", attribute: "href"},
+	{element: "iframe", attribute: "src"},
+	{element: "link", attribute: "href", resource: true, when: relIs(resourceRels...)}, // Stylesheets and favicons
+	{element: "link", attribute: "href", when: not(relIs(resourceRels...))},            // i.e. canonical, alternate
+	{element: "script", attribute: "src", resource: true},
+	{element: "img", attribute: "src", resource: true},
+	{element: "input", attribute: "src", resource: true},
+	{element: "video", attribute: "src", resource: true},
+	{element: "video", attribute: "poster", resource: true},
+	{element: "audio", attribute: "src", resource: true},
+	{element: "source", attribute: "src", resource: true},
+	{element: "track", attribute: "src", resource: true},
+	{element: "embed", attribute: "src", resource: true},
+	{element: "object", attribute: "data", resource: true},
+	{element: "*", attribute: "data-src", resource: true}, // Lazy loading of WordPress themes and plugins
+	{element: "*", attribute: "data-lazy-src", resource: true},
+	{element: "*", attribute: "data-bg", resource: true},
+	{element: "*", attribute: "data-background", resource: true},
+	{element: "*", attribute: "style", resource: true, css: true}, // i.e. featured images as background of the header
+	{element: "meta", attribute: "content", resource: true, when: metaIs("og:image", "og:image:url", "og:image:secure_url", "twitter:image", "msapplication-TileImage")},
+}
+
+var (
+	tagRegEx       = regexp.MustCompile(`(?s)<([a-zA-Z][a-zA-Z0-9-]*)\b((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	attributeRegEx = regexp.MustCompile(`([^\s"'<>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	cssURLRegEx    = regexp.MustCompile(`url\(\s*["']?(.*?)["']?\s*\)`)
+	cssImportRegEx = regexp.MustCompile(`@import\s+["']([^"']+)["']`) // @import without url()
+	commentRegEx   = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+/*
+	Returns all references of the document in the order they appear, references in comments are left out
+*/
+func Extract(content string) []Reference {
+	var references []Reference
+	content = commentRegEx.ReplaceAllString(content, "")
+
+	for _, tag := range tagRegEx.FindAllStringSubmatch(content, -1) {
+		element := strings.ToLower(tag[1])
+		attributes := make(map[string]string) // Name -> raw value
+		for _, attribute := range attributeRegEx.FindAllStringSubmatch(tag[2], -1) {
+			name := strings.ToLower(attribute[1])
+			if _, ok := attributes[name]; !ok { // Like browsers the first attribute wins
+				attributes[name] = attribute[2] + attribute[3] + attribute[4]
+			}
+		}
+
+		for _, r := range rules {
+			if r.element != "*" && r.element != element {
+				continue
+			}
+			raw, ok := attributes[r.attribute]
+			if !ok || (r.when != nil && !r.when(attributes)) {
+				continue
+			}
+			if r.css {
+				for _, match := range cssURLRegEx.FindAllStringSubmatch(html.UnescapeString(raw), -1) {
+					references = appendReference(references, Reference{URL: match[1], Raw: match[1], Element: element, Attribute: r.attribute, Resource: r.resource})
+				}
+				continue
+			}
+			references = appendReference(references, Reference{URL: html.UnescapeString(raw), Raw: raw, Element: element, Attribute: r.attribute, Resource: r.resource})
+		}
+	}
+	return references
+}
+
+/*
+	Urls of all url() in a stylesheet, and of @import "[url]" which is the same as @import url("[url]")
+*/
+func ExtractCSS(content string) []string {
+	var urls []string
+	matches := append(cssURLRegEx.FindAllStringSubmatch(content, -1), cssImportRegEx.FindAllStringSubmatch(content, -1)...)
+	for _, match := range matches {
+		if url := strings.TrimSpace(match[1]); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+/*
+	Replaces every url() in a stylesheet whose url replace maps to a new one, url() is kept where replace returns an empty string
+*/
+func ReplaceCSS(content string, replace func(url string) string) string {
+	return cssURLRegEx.ReplaceAllStringFunc(content, func(call string) string {
+		if url := replace(strings.TrimSpace(cssURLRegEx.FindStringSubmatch(call)[1])); url != "" {
+			return `url("` + url + `")`
+		}
+		return call
+	})
+}
+
+/*
+	References that can not be followed: empty, anchors on the same page and other schemes than http
+*/
+func IsLocal(url string) bool {
+	url = strings.TrimSpace(url)
+	for _, prefix := range []string{"#", "mailto:", "tel:", "javascript:", "data:"} {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return url == ""
+}
+
+func appendReference(references []Reference, reference Reference) []Reference {
+	reference.URL = strings.TrimSpace(reference.URL)
+	if IsLocal(reference.URL) {
+		return references
+	}
+	return append(references, reference)
+}
+
+func hasAttribute(name string) func(map[string]string) bool {
+	return func(attributes map[string]string) bool {
+		_, ok := attributes[name]
+		return ok
+	}
+}
+
+func not(condition func(map[string]string) bool) func(map[string]string) bool {
+	return func(attributes map[string]string) bool {
+		return !condition(attributes)
+	}
+}
+
+func relIs(values ...string) func(map[string]string) bool {
+	return func(attributes map[string]string) bool {
+		rel := strings.ToLower(strings.TrimSpace(attributes["rel"]))
+		for _, value := range values {
+			if rel == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func metaIs(values ...string) func(map[string]string) bool {
+	return func(attributes map[string]string) bool {
+		for _, key := range []string{"property", "name", "itemprop"} {
+			for _, value := range values {
+				if strings.EqualFold(attributes[key], value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/elliotchance/orderedmap"
 )
@@ -52,71 +54,157 @@ func createAwardMap(order []string, urls map[string]string) *orderedmap.OrderedM
 }
 
 /*
-* Checks if a page is reachable via the URLs defined in ... and returns if it is reachable and if the "DO NOT JUDGE" hint has been removed.
-* Returns a formated string with the result.
+* Result of the check of one medal criterion or award page.
+* Sections are the headlines between the criteria (i.e. Medals, Awards), they have no page and no state.
  */
-func CheckCriteria(order []string, urls map[string]string, team string, year int, url bool) (string, error) {
-	result := ""
-	var err error
-	baseURL := "https://" + fmt.Sprint(year) + ".igem.org/Team:" + team + "/"
+type CriterionResult struct {
+	Medal   string  `json:"medal"`
+	URL     string  `json:"url,omitempty"`
+	Status  int     `json:"status,omitempty"` // HTTP status code
+	State   State   `json:"state"`
+	Issues  []Issue `json:"issues,omitempty"`
+	Section bool    `json:"section,omitempty"`
+}
+
+/*
+* Problem found on a criterion page, Kind is a short machine readable identifier
+ */
+type Issue struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+type State int
+
+const (
+	OK          State = iota // Page is reachable and visible to the judges
+	NotFound                 // Page returns 404
+	Empty                    // Page exists, but has no content or has been purged
+	JudgesAlert              // Page still shows the alert that judges will not evaluate it
+	Error                    // Any other HTTP status
+	Incomplete               // Page is visible, but the validation of its content found issues
+)
+
+var stateNames = [...]string{"OK", "NotFound", "Empty", "JudgesAlert", "Error", "Incomplete"}
+
+func (s State) String() string {
+	return stateNames[s]
+}
+
+func (s State) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+/*
+* Human readable description of the state, as printed by the text renderer
+ */
+func (s State) Message() string {
+	return [...]string{
+		"Page seems to be okay, check anyways!",
+		"Page Not Found, check your URLs!",
+		"Page is Empty, check your URLs!",
+		"Page is NOT visible to judges, remove the ALERT message!",
+		"Unknown Error!",
+		"Page is visible, but incomplete, check the issues!",
+	}[s]
+}
+
+const parallelChecks = 5 // Number of pages that are checked at the same time, to not flood the iGEM servers
+
+/*
+* Checks if a page is reachable via the URLs defined in the config and returns if it is reachable and if the "DO NOT JUDGE" hint has been removed.
+* The content of every visible page is validated against the requirements of its criterion, see validatePage.
+* The pages are checked concurrently, every request times out after timeout seconds. A failed request only marks its criterion as Error.
+* wiki is the root of the wiki of the year, i.e. https://2021.igem.org
+* Returns one result per entry of the order, in the same order.
+ */
+func CheckCriteria(order []string, urls map[string]string, requirements map[string]Requirements, wiki, team string, timeout int) []CriterionResult {
+	baseURL := wiki + "/Team:" + team + "/"
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	links := &linkCache{status: make(map[string]int)}
 
 	awardMap := createAwardMap(order, urls)
+	results := make([]CriterionResult, awardMap.Len())
 
+	var wg sync.WaitGroup
+	pool := make(chan struct{}, parallelChecks)
+	i := 0
 	for el := awardMap.Front(); el != nil; el = el.Next() {
 		medal := el.Key.(string)
 
 		if el.Value.(string) == "#" {
-			result += "########################################################\n"
-			result += "############################" + medal + "#####################\n"
-			result += "########################################################\n"
+			results[i] = CriterionResult{Medal: medal, Section: true}
+			i++
 			continue
 		}
 
-		link := baseURL + el.Value.(string)
-		resp, err := http.Get(link)
+		wg.Add(1)
+		go func(i int, medal, link string) {
+			defer wg.Done()
+			pool <- struct{}{}
+			results[i] = checkPage(medal, link, requirements[medal], client, links)
+			<-pool
+		}(i, medal, baseURL+el.Value.(string))
+		i++
+	}
+	wg.Wait()
+
+	return results
+}
+
+/*
+* Checks a single criterion page, request errors are stored in the result
+ */
+func checkPage(medal, link string, req Requirements, client *http.Client, links *linkCache) CriterionResult {
+	result := CriterionResult{Medal: medal, URL: link}
+	resp, err := client.Get(link)
+	if err != nil {
+		result.State = Error
+		result.addIssue("request", "Request failed! "+err.Error())
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	if resp.StatusCode == 404 {
+		result.setState(NotFound, "notFound")
+	} else if resp.StatusCode == 200 {
+		byteBody, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return "", err
+			result.State = Error
+			result.addIssue("request", "Request failed! "+err.Error())
+			return result
+		}
+		body := string(byteBody)
+
+		if strings.Contains(body, `cnoarticletext`) || strings.Contains(body, `purged-page-empty`) || strings.Contains(body, `(page does not exist)`) {
+			result.setState(Empty, "empty")
+		} else if strings.Contains(body, `judges-will-not-evaluate`) {
+			result.setState(JudgesAlert, "judgesAlert")
 		}
-		if resp.StatusCode == 404 {
-			if url {
-				result += medal + ": Page Not Found, check your URLs! " + link + "\n"
-			} else {
-				result += medal + ": Page Not Found, check your URLs!\n"
-			}
-		} else if resp.StatusCode == 200 {
-			byteBody, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return "", err
-			}
-			body := string(byteBody)
-
-			if strings.Contains(body, `cnoarticletext`) || strings.Contains(body, `purged-page-empty`) || strings.Contains(body, `(page does not exist)`) {
-				if url {
-					result += medal + ": Page is Empty, check your URLs! " + link + "\n"
-				} else {
-					result += medal + ": Page is Empty, check your URLs!\n"
-				}
-			} else if strings.Contains(body, `judges-will-not-evaluate`) {
-				if url {
-					result += medal + ": Page is NOT visible to judges, remove the ALERT message! " + link + "\n"
-				} else {
-					result += medal + ": Page is NOT visible to judges, remove the ALERT message!\n"
-				}
-			} else {
-				if url {
-					result += medal + ": Page seems to be okay, check anyways! " + link + "\n"
-				} else {
-					result += medal + ": Page seems to be okay, check anyways!\n"
-				}
-			}
-		} else {
-			if url {
-				result += medal + ": Unknown Error! " + fmt.Sprint(resp.StatusCode) + ": " + link + "\n"
-			}
-			result += medal + ": Unknown Error! " + fmt.Sprint(resp.StatusCode) + "\n"
+		if result.State != Empty {
+			validatePage(&result, resp, body, req, client, links)
 		}
+	} else {
+		result.State = Error
+		result.Issues = append(result.Issues, Issue{Kind: "httpStatus", Message: "Unknown Error! " + fmt.Sprint(resp.StatusCode)})
 	}
+	return result
+}
 
-	return result, err
+/*
+* Returns true if all criteria pages are OK
+ */
+func Passed(results []CriterionResult) bool {
+	for _, r := range results {
+		if !r.Section && r.State != OK {
+			return false
+		}
+	}
+	return true
+}
 
+func (r *CriterionResult) setState(state State, kind string) {
+	r.State = state
+	r.Issues = append(r.Issues, Issue{Kind: kind, Message: state.Message()})
 }
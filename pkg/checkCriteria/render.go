@@ -0,0 +1,158 @@
+package GoGEMcheckcriteria
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+/*
+* Renderers for the results of CheckCriteria.
+* Text is meant for the terminal, JSON for scripts, Markdown for issues and pull requests and JUnit-XML for CI pipelines, which show every criterion as a test case.
+ */
+
+/*
+* Renders the results as text, with the hash banners for the sections. If url is true the checked URL is added to every result.
+ */
+func RenderText(results []CriterionResult, url bool) string {
+	result := ""
+	for _, r := range results {
+		if r.Section {
+			result += "########################################################\n"
+			result += "############################" + r.Medal + "#####################\n"
+			result += "########################################################\n"
+			continue
+		}
+		line := r.Medal + ": " + r.summary()
+		if url {
+			line += " " + r.URL
+		}
+		result += line + "\n"
+	}
+	return result
+}
+
+/*
+* Renders the results as JSON array, sections are left out as they carry no result
+ */
+func RenderJSON(results []CriterionResult) ([]byte, error) {
+	criteria := []CriterionResult{}
+	for _, r := range results {
+		if !r.Section {
+			criteria = append(criteria, r)
+		}
+	}
+	return json.MarshalIndent(criteria, "", "  ")
+}
+
+/*
+* Renders the results as Markdown, one table per section
+ */
+func RenderMarkdown(results []CriterionResult) string {
+	result := ""
+	header := "| Criterion | State | Page |\n| --- | --- | --- |\n"
+	table := false
+	for _, r := range results {
+		if r.Section {
+			result += "\n## " + r.Medal + "\n\n"
+			table = false
+			continue
+		}
+		if !table {
+			result += header
+			table = true
+		}
+		result += fmt.Sprintf("| %s | %s %s | [%s](%s) |\n", escapeMarkdown(r.Medal), stateIcon(r.State), escapeMarkdown(r.summary()), r.URL, r.URL)
+	}
+	return strings.TrimLeft(result, "\n")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+/*
+* Renders the results as JUnit-XML, every section becomes a test suite and every criterion that is not OK a failed test case
+ */
+func RenderJUnit(results []CriterionResult) ([]byte, error) {
+	suites := junitTestSuites{}
+	for _, r := range results {
+		if r.Section || len(suites.Suites) == 0 {
+			name := "Criteria"
+			if r.Section {
+				name = r.Medal
+			}
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: name})
+			if r.Section {
+				continue
+			}
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+		testcase := junitTestCase{Name: r.Medal, ClassName: suite.Name}
+		if r.State != OK {
+			testcase.Failure = &junitFailure{Message: r.summary(), Type: r.State.String(), Text: r.URL}
+			suite.Failures++
+			suites.Failures++
+		}
+		suite.Cases = append(suite.Cases, testcase)
+		suite.Tests++
+		suites.Tests++
+	}
+
+	content, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+/*
+* All issues of the result in one line, or the message of the state if there are none
+ */
+func (r CriterionResult) summary() string {
+	if len(r.Issues) == 0 {
+		return r.State.Message()
+	}
+	var messages []string
+	for _, issue := range r.Issues {
+		messages = append(messages, issue.Message)
+	}
+	return strings.Join(messages, " ")
+}
+
+func stateIcon(state State) string {
+	if state == OK {
+		return "✅"
+	}
+	if state == Incomplete {
+		return "⚠️"
+	}
+	return "❌"
+}
+
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,231 @@
+package GoGEMcheckcriteria
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+* Requirements for the content of a criterion page, configured per criterion in the Criteria of the config file:
+* "Criteria": [{"Bronze #2 (Attributions)": {"MinLength": 500, "Sections": ["Team"], "Keywords": ["funding"]}}]
+* Sections are matched against the headings of the page, keywords against the text, both case insensitive.
+ */
+type Requirements struct {
+	MinLength int      `mapstructure:"minlength"`
+	Sections  []string `mapstructure:"sections"`
+	Keywords  []string `mapstructure:"keywords"`
+}
+
+var (
+	scriptStyleRegEx = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	tagRegEx         = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRegEx       = regexp.MustCompile(`\s+`)
+	headingRegEx     = regexp.MustCompile(`(?is)<h[1-6]\b[^>]*>(.*?)</h[1-6]>`)
+	anchorRegEx      = regexp.MustCompile(`(?is)<a\b[^>]*?\shref=("|')(.*?)("|')`)
+	imageRegEx       = regexp.MustCompile(`(?is)<img\b[^>]*?\ssrc=("|')(.*?)("|')`)
+	resourceRegEx    = regexp.MustCompile(`(?is)<(?:script|img|iframe|video|audio|source|embed)\b[^>]*?\ssrc=["'](.*?)["']|<link\b[^>]*?\shref=["'](.*?)["']|url\(\s*["']?(.*?)["']?\s*\)`)
+)
+
+/*
+* Validates the content of a reachable criterion page, every problem is added as a distinct issue:
+* tooShort, missingSection, missingKeyword, brokenLink, brokenImage, externalResource and redirect.
+* links caches the status of already checked links, as criteria pages share most of their links.
+ */
+func validatePage(result *CriterionResult, resp *http.Response, body string, req Requirements, client *http.Client, links *linkCache) {
+	if isRedirect(resp, body, result.URL) {
+		result.addIssue("redirect", "Page is only a redirect, link the target page directly.")
+	}
+
+	content := pageContent(body)
+	text := pageText(content)
+	lowerText := strings.ToLower(text)
+
+	if length := utf8.RuneCountInString(text); req.MinLength > 0 && length < req.MinLength { // Characters, not bytes
+		result.addIssue("tooShort", fmt.Sprintf("Page has only %d of at least %d characters.", length, req.MinLength))
+	}
+
+	var headings []string
+	for _, match := range headingRegEx.FindAllStringSubmatch(content, -1) {
+		headings = append(headings, strings.ToLower(pageText(match[1])))
+	}
+	for _, section := range req.Sections {
+		found := false
+		for _, heading := range headings {
+			if strings.Contains(heading, strings.ToLower(section)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.addIssue("missingSection", "Section \""+section+"\" is missing.")
+		}
+	}
+
+	for _, keyword := range req.Keywords {
+		if !strings.Contains(lowerText, strings.ToLower(keyword)) {
+			result.addIssue("missingKeyword", "Keyword \""+keyword+"\" is missing.")
+		}
+	}
+
+	for _, match := range anchorRegEx.FindAllStringSubmatch(content, -1) {
+		if link, ok := internalURL(result.URL, html.UnescapeString(match[2])); ok && isBroken(link, client, links) {
+			result.addIssue("brokenLink", "Broken link to "+link+".")
+		}
+	}
+	for _, match := range imageRegEx.FindAllStringSubmatch(content, -1) {
+		if link, ok := internalURL(result.URL, html.UnescapeString(match[2])); ok && isBroken(link, client, links) {
+			result.addIssue("brokenImage", "Broken image "+link+".")
+		}
+	}
+
+	for _, match := range resourceRegEx.FindAllStringSubmatch(content, -1) {
+		resource := match[1] + match[2] + match[3] // Only one of the alternatives matches
+		if isExternal(resource) {
+			result.addIssue("externalResource", "External resource "+resource+" will be blocked by iGEM, upload it to the Wiki.")
+		}
+	}
+
+	if len(result.Issues) > 0 && result.State == OK {
+		result.State = Incomplete
+	}
+}
+
+/*
+* Either the request was redirected, or MediaWiki followed a #REDIRECT page
+ */
+func isRedirect(resp *http.Response, body, link string) bool {
+	if resp.Request != nil && canonicalURL(resp.Request.URL.String()) != canonicalURL(link) {
+		return true
+	}
+	return strings.Contains(body, `class="mw-redirectedfrom"`) || strings.Contains(body, `"wgIsRedirect":true`)
+}
+
+/*
+* Url in a form that is equal for all spellings of the same page: scheme and host in lower case without the default port,
+* the path unescaped (i.e. %27 and ') without a trailing slash, the query sorted and the fragment removed
+ */
+func canonicalURL(link string) string {
+	u, err := neturl.Parse(link)
+	if err != nil {
+		return link
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	return scheme + "://" + host + strings.TrimSuffix(u.Path, "/") + "?" + u.Query().Encode()
+}
+
+/*
+* Cuts the content of the team out of the page, without the iGEM navigation and footer
+ */
+func pageContent(body string) string {
+	if start := strings.Index(body, `id="mw-content-text"`); start >= 0 {
+		body = body[start:]
+	}
+	for _, marker := range []string{`class="printfooter"`, `id="catlinks"`} {
+		if end := strings.Index(body, marker); end >= 0 {
+			body = body[:end]
+		}
+	}
+	return body
+}
+
+/*
+* Visible text of the html, with collapsed whitespace
+ */
+func pageText(content string) string {
+	content = scriptStyleRegEx.ReplaceAllString(content, " ")
+	content = tagRegEx.ReplaceAllString(content, " ")
+	content = html.UnescapeString(content)
+	return strings.TrimSpace(spaceRegEx.ReplaceAllString(content, " "))
+}
+
+/*
+* Resolves the link against the page, returns false for links that do not lead to a page or file on the Wiki
+ */
+func internalURL(page, link string) (string, bool) {
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "javascript:") {
+		return "", false
+	}
+	base, err := neturl.Parse(page)
+	if err != nil {
+		return "", false
+	}
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	resolved := base.ResolveReference(ref)
+	if !isIGEMHost(resolved.Hostname()) {
+		return "", false
+	}
+	resolved.Fragment = ""
+	return resolved.String(), true
+}
+
+/*
+* Status of the links checked so far, shared by all concurrent checks
+ */
+type linkCache struct {
+	sync.Mutex
+	status map[string]int
+}
+
+/*
+* Red links of MediaWiki point to pages that do not exist, all other links are requested once
+ */
+func isBroken(link string, client *http.Client, links *linkCache) bool {
+	if strings.Contains(link, "redlink=1") {
+		return true
+	}
+	links.Lock()
+	status, ok := links.status[link]
+	links.Unlock()
+	if !ok {
+		resp, err := client.Head(link)
+		if err != nil {
+			status = 0
+		} else {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		links.Lock()
+		links.status[link] = status
+		links.Unlock()
+	}
+	return status == 0 || status == 404 || status == 410
+}
+
+/*
+* Resources are external if they are loaded from any other host than igem.org, iGEM blocks them on the Wiki
+ */
+func isExternal(resource string) bool {
+	if strings.HasPrefix(resource, "data:") {
+		return false
+	}
+	u, err := neturl.Parse(strings.TrimSpace(resource))
+	if err != nil || u.Host == "" {
+		return false // Relative, so it is on the Wiki
+	}
+	return !isIGEMHost(u.Hostname())
+}
+
+/*
+* igem.org and its subdomains, but not hosts that only end with igem.org (i.e. notigem.org)
+ */
+func isIGEMHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "igem.org" || strings.HasSuffix(host, ".igem.org")
+}
+
+func (r *CriterionResult) addIssue(kind, message string) {
+	r.Issues = append(r.Issues, Issue{Kind: kind, Message: message})
+}
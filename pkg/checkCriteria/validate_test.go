@@ -0,0 +1,32 @@
+package GoGEMcheckcriteria
+
+import (
+	"net/http"
+	neturl "net/url"
+	"testing"
+)
+
+func TestIsRedirect(t *testing.T) {
+	tests := []struct {
+		link, requested string
+		want            bool
+	}{
+		{"https://2021.igem.org/Team:TU_Darmstadt/Attributions", "https://2021.igem.org/Team:TU_Darmstadt/Attributions", false},
+		{"https://2021.igem.org/Team:TU_Darmstadt/Team's_Page", "https://2021.igem.org/Team:TU_Darmstadt/Team%27s_Page", false},
+		{"https://2021.igem.org:443/Team:TU_Darmstadt/Attributions", "https://2021.igem.org/Team:TU_Darmstadt/Attributions", false},
+		{"HTTPS://2021.iGEM.org/Team:TU_Darmstadt/Attributions/", "https://2021.igem.org/Team:TU_Darmstadt/Attributions", false},
+		{"https://2021.igem.org/Team:TU_Darmstadt/Attributions", "https://2021.igem.org/Team:TU_Darmstadt/Contribution", true},
+		{"http://2021.igem.org/Team:TU_Darmstadt/Attributions", "https://2021.igem.org/Team:TU_Darmstadt/Attributions", true},
+		{"https://2021.igem.org:8443/Team:TU_Darmstadt/Attributions", "https://2021.igem.org/Team:TU_Darmstadt/Attributions", true},
+	}
+	for _, test := range tests {
+		requested, err := neturl.Parse(test.requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := &http.Response{Request: &http.Request{URL: requested}}
+		if got := isRedirect(resp, "", test.link); got != test.want {
+			t.Errorf("isRedirect(%s, %s) = %v, want %v", test.requested, test.link, got, test.want)
+		}
+	}
+}